@@ -0,0 +1,419 @@
+// Package cas implements a content-addressed block store with
+// manifest-based collections, modeled on Arvados Keep: data is split into
+// blocks keyed by their own content hash, and a path is a manifest that
+// references byte ranges within those blocks.
+package cas
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+)
+
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name:        "cas",
+		Description: "Content-addressed block store with manifest collections",
+		NewFs:       NewFs,
+		Options: []fs.Option{{
+			Name: "remote",
+			Help: "Remote to use as the underlying block store, e.g. \"remote:path\".",
+		}, {
+			Name: "hmac_key",
+			Help: "Optional key used to sign locators, e.g. \"+A<sig>@<expiry>\". Leave blank to disable signing.",
+		}},
+	})
+}
+
+// Options for this backend
+type Options struct {
+	Remote  string `config:"remote"`
+	HMACKey string `config:"hmac_key"`
+}
+
+// Fs stores content-addressed blocks plus a manifest per collection on
+// top of any other rclone remote
+type Fs struct {
+	name     string
+	root     string
+	opt      Options
+	blocks   fs.Fs // backing store for "<md5>+<len>" block objects
+	features *fs.Features
+}
+
+// Object is a single logical path, backed by a manifest line that
+// references one or more blocks
+type Object struct {
+	f        *Fs
+	remote   string
+	size     int64
+	modTime  time.Time
+	locators []locator // the blocks (and byte ranges) that make up this object
+}
+
+// locator identifies one block, optionally signed
+type locator struct {
+	Hash    string
+	Len     int64
+	Offset  int64  // offset within the block this range starts at
+	Size    int64  // length of this range
+	Locator string // the exact text recorded in the manifest for this block - the unsigned "<hash>+<len>" storage key, or that key plus a "+A<sig>@<expiry>" token when hmac_key is set
+}
+
+// NewFs constructs a cas Fs backed by opt.Remote
+func NewFs(name, root string) (fs.Fs, error) {
+	opt := new(Options)
+	if err := fs.ConfigFileGetStruct(name, opt); err != nil {
+		return nil, err
+	}
+	blocks, err := fs.NewFs(opt.Remote)
+	if err != nil {
+		return nil, fmt.Errorf("cas: failed to make backing remote %q: %w", opt.Remote, err)
+	}
+	f := &Fs{name: name, root: root, opt: *opt, blocks: blocks}
+	f.features = (&fs.Features{
+		ContentAddressed: true,
+	}).Fill(f)
+	return f, nil
+}
+
+// Name of the remote
+func (f *Fs) Name() string { return f.name }
+
+// Root of the remote
+func (f *Fs) Root() string { return f.root }
+
+// String converts this Fs to a string
+func (f *Fs) String() string { return fmt.Sprintf("cas:%s", f.blocks.String()) }
+
+// Precision passed through from the backing store
+func (f *Fs) Precision() time.Duration { return f.blocks.Precision() }
+
+// Hashes: equality between two cas Objects is a manifest-locator
+// comparison, so the native block hash (MD5) is always available
+func (f *Fs) Hashes() fs.HashSet { return fs.HashSet(fs.HashMD5) }
+
+// Features returns the optional features of this Fs, including
+// ContentAddressed so sync can short-circuit when both sides advertise
+// it and locators match
+func (f *Fs) Features() *fs.Features { return f.features }
+
+// blockStorageKey is the storage key for a block in the backing blocks
+// Fs: always the unsigned "<md5>+<len>" form. Signing only wraps the
+// locator recorded in the manifest (see signLocator) - the actual
+// storage path never changes, so content-addressed dedup keeps working
+// the same way whether or not hmac_key is set.
+func blockStorageKey(md5sum string, size int64) string {
+	return fmt.Sprintf("%s+%d", md5sum, size)
+}
+
+// signLocator wraps key in an HMAC token of the form +A<sig>@<expiry>
+// for the manifest to record, or returns key unchanged if hmac_key isn't set
+func (f *Fs) signLocator(key string) string {
+	if f.opt.HMACKey == "" {
+		return key
+	}
+	expiry := time.Now().Add(24 * time.Hour).Unix()
+	mac := hmac.New(sha256.New, []byte(f.opt.HMACKey))
+	fmt.Fprintf(mac, "%s@%d", key, expiry)
+	sig := hex.EncodeToString(mac.Sum(nil))
+	return fmt.Sprintf("%s+A%s@%d", key, sig, expiry)
+}
+
+// verifyLocator checks an HMAC-signed locator hasn't expired or been
+// tampered with, and returns the underlying blockStorageKey to look the
+// block up by. Unsigned locators pass through unchecked.
+func (f *Fs) verifyLocator(loc string) (string, error) {
+	idx := strings.Index(loc, "+A")
+	if idx < 0 {
+		return loc, nil
+	}
+	if f.opt.HMACKey == "" {
+		return "", fmt.Errorf("cas: locator %q is signed but no hmac_key is configured", loc)
+	}
+	key := loc[:idx]
+	sigExpiry := strings.SplitN(loc[idx+len("+A"):], "@", 2)
+	if len(sigExpiry) != 2 {
+		return "", fmt.Errorf("cas: malformed signed locator %q", loc)
+	}
+	expiry, err := strconv.ParseInt(sigExpiry[1], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("cas: malformed locator expiry: %w", err)
+	}
+	if time.Now().Unix() > expiry {
+		return "", fmt.Errorf("cas: locator %q has expired", loc)
+	}
+	mac := hmac.New(sha256.New, []byte(f.opt.HMACKey))
+	fmt.Fprintf(mac, "%s@%d", key, expiry)
+	want := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(want), []byte(sigExpiry[0])) {
+		return "", fmt.Errorf("cas: locator %q has an invalid signature", loc)
+	}
+	return key, nil
+}
+
+// manifestPath is where the manifest line for remote is stored
+func manifestPath(remote string) string { return ".cas_manifests/" + remote + ".manifest" }
+
+// manifestDirPrefix is the .cas_manifests subtree that mirrors dir
+func manifestDirPrefix(dir string) string {
+	if dir == "" {
+		return ".cas_manifests"
+	}
+	return ".cas_manifests/" + dir
+}
+
+// List resolves the .cas_manifests mirror of dir back into the logical
+// paths it stores manifests for
+func (f *Fs) List(dir string) (fs.DirEntries, error) {
+	entries, err := fs.ListDirSorted(f.blocks, true, manifestDirPrefix(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	var out fs.DirEntries
+	for _, e := range entries {
+		switch v := e.(type) {
+		case fs.Directory:
+			remote := strings.TrimPrefix(v.Remote(), ".cas_manifests/")
+			out = append(out, fs.NewDir(remote, v.ModTime()))
+		case fs.Object:
+			if !strings.HasSuffix(v.Remote(), ".manifest") {
+				continue
+			}
+			remote := strings.TrimSuffix(strings.TrimPrefix(v.Remote(), ".cas_manifests/"), ".manifest")
+			o, err := f.NewObject(remote)
+			if err != nil {
+				continue
+			}
+			out = append(out, o)
+		}
+	}
+	return out, nil
+}
+
+// NewObject reads the manifest for remote and resolves its locators
+func (f *Fs) NewObject(remote string) (fs.Object, error) {
+	mo, err := f.blocks.NewObject(manifestPath(remote))
+	if err != nil {
+		return nil, fs.ErrorObjectNotFound
+	}
+	rc, err := mo.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	line, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	return parseManifestLine(f, remote, string(line))
+}
+
+// parseManifestLine decodes "./dir <locator1> <locator2> ... <off:len:name>"
+func parseManifestLine(f *Fs, remote, line string) (*Object, error) {
+	fields := strings.Fields(strings.TrimSpace(line))
+	var locs []locator
+	var size int64
+	var modTime time.Time
+	for _, field := range fields {
+		if strings.Contains(field, ":") && strings.Count(field, ":") == 2 {
+			parts := strings.SplitN(field, ":", 3)
+			length, _ := strconv.ParseInt(parts[1], 10, 64)
+			size += length
+			_ = parts[2] // file name within the manifest line, already == remote here
+			continue
+		}
+		if strings.Contains(field, "+") {
+			prefix := field
+			if idx := strings.Index(field, "+A"); idx >= 0 {
+				prefix = field[:idx]
+			}
+			parts := strings.SplitN(prefix, "+", 2)
+			length, _ := strconv.ParseInt(parts[1], 10, 64)
+			locs = append(locs, locator{Hash: parts[0], Len: length, Size: length, Locator: field})
+		}
+	}
+	return &Object{f: f, remote: remote, size: size, modTime: modTime, locators: locs}, nil
+}
+
+// Put splits src into blocks, writes each block under its content
+// address, and records a manifest line referencing them
+func (f *Fs) Put(in io.Reader, src fs.ObjectInfo) (fs.Object, error) {
+	const blockSize = 8 << 20 // 8MiB fixed blocks; CDC is left to fs.ChunkDiff callers
+	var locs []locator
+	var total int64
+
+	buf := make([]byte, blockSize)
+	for {
+		n, rerr := io.ReadFull(in, buf)
+		if n > 0 {
+			sum := md5.Sum(buf[:n])
+			hexSum := hex.EncodeToString(sum[:])
+			key := blockStorageKey(hexSum, int64(n))
+			if _, err := f.blocks.NewObject(key); err != nil {
+				// block doesn't exist yet - write it. Already-present
+				// blocks are left untouched, which is where the
+				// automatic dedup comes from.
+				info := fs.NewStaticObjectInfo(key, time.Now(), int64(n), true, nil, nil)
+				if _, err := f.blocks.Put(strings.NewReader(string(buf[:n])), info); err != nil {
+					return nil, fmt.Errorf("cas: failed to write block %s: %w", key, err)
+				}
+			}
+			locs = append(locs, locator{Hash: hexSum, Len: int64(n), Size: int64(n), Locator: f.signLocator(key)})
+			total += int64(n)
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			return nil, rerr
+		}
+	}
+
+	if err := f.writeManifest(src.Remote(), locs, total); err != nil {
+		return nil, err
+	}
+	return &Object{f: f, remote: src.Remote(), size: total, modTime: src.ModTime(), locators: locs}, nil
+}
+
+func (f *Fs) writeManifest(remote string, locs []locator, size int64) error {
+	var b strings.Builder
+	b.WriteString("./\x20")
+	for _, l := range locs {
+		fmt.Fprintf(&b, "%s ", l.Locator)
+	}
+	fmt.Fprintf(&b, "0:%d:%s\n", size, remote)
+
+	info := fs.NewStaticObjectInfo(manifestPath(remote), time.Now(), int64(b.Len()), true, nil, nil)
+	_, err := f.blocks.Put(strings.NewReader(b.String()), info)
+	return err
+}
+
+// Mkdir is a no-op: directories are implicit in manifest paths
+func (f *Fs) Mkdir(dir string) error { return nil }
+
+// Rmdir is a no-op for the same reason
+func (f *Fs) Rmdir(dir string) error { return nil }
+
+// Fs returns the parent Fs
+func (o *Object) Fs() fs.Info { return o.f }
+
+// Remote returns the remote path
+func (o *Object) Remote() string { return o.remote }
+
+// String returns a description of the object
+func (o *Object) String() string { return o.remote }
+
+// Size returns the logical size of the object
+func (o *Object) Size() int64 { return o.size }
+
+// ModTime returns the modification time
+func (o *Object) ModTime() time.Time { return o.modTime }
+
+// Hash returns a digest over the object's manifest locators, which is
+// how two cas Objects are compared without transferring any bytes.
+// Object equality is a manifest-locator comparison, not a single
+// block's hash, so for a multi-block object this hashes the full
+// ordered locator list rather than just the first block; a
+// single-block object's Hash still equals that block's own MD5.
+func (o *Object) Hash(ht fs.HashType) (string, error) {
+	if ht != fs.HashMD5 || len(o.locators) == 0 {
+		return "", fs.ErrHashUnsupported
+	}
+	if len(o.locators) == 1 {
+		return o.locators[0].Hash, nil
+	}
+	h := md5.New()
+	for _, l := range o.locators {
+		fmt.Fprintf(h, "%s+%d,", l.Hash, l.Len)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Storable returns whether this object can be stored
+func (o *Object) Storable() bool { return true }
+
+// SetModTime sets the object's modtime in memory and rewrites its
+// manifest; the manifest line records no modtime, so an Object read back
+// through NewObject or List has a zero ModTime
+func (o *Object) SetModTime(t time.Time) error {
+	o.modTime = t
+	return o.f.writeManifest(o.remote, o.locators, o.size)
+}
+
+// Open resolves the manifest's locators and serves a concatenated reader
+// across the referenced blocks
+func (o *Object) Open(options ...fs.OpenOption) (io.ReadCloser, error) {
+	readers := make([]io.Reader, 0, len(o.locators))
+	closers := make([]io.Closer, 0, len(o.locators))
+	for _, loc := range o.locators {
+		key, err := o.f.verifyLocator(loc.Locator)
+		if err != nil {
+			return nil, err
+		}
+		bo, err := o.f.blocks.NewObject(key)
+		if err != nil {
+			return nil, fmt.Errorf("cas: missing block %s referenced by manifest: %w", key, err)
+		}
+		rc, err := bo.Open()
+		if err != nil {
+			return nil, err
+		}
+		readers = append(readers, rc)
+		closers = append(closers, rc)
+	}
+	return &multiReadCloser{r: io.MultiReader(readers...), closers: closers}, nil
+}
+
+type multiReadCloser struct {
+	r       io.Reader
+	closers []io.Closer
+}
+
+func (m *multiReadCloser) Read(p []byte) (int, error) { return m.r.Read(p) }
+func (m *multiReadCloser) Close() error {
+	var firstErr error
+	for _, c := range m.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Update replaces the object's content with a fresh Put
+func (o *Object) Update(in io.Reader, src fs.ObjectInfo) error {
+	updated, err := o.f.Put(in, src)
+	if err != nil {
+		return err
+	}
+	*o = *(updated.(*Object))
+	return nil
+}
+
+// Remove deletes the manifest. Blocks are left in place since the same
+// block may be referenced by other manifests - use `rclone cas gc` to
+// reclaim unreferenced ones.
+func (o *Object) Remove() error {
+	mo, err := o.f.blocks.NewObject(manifestPath(o.remote))
+	if err != nil {
+		return err
+	}
+	return mo.Remove()
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs     = (*Fs)(nil)
+	_ fs.Object = (*Object)(nil)
+)
@@ -1,12 +1,18 @@
 package config
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/rmdashrf/rclone_acd_hack/cmd"
 	"github.com/rmdashrf/rclone_acd_hack/fs"
 	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
 )
 
 func init() {
+	commandDefintion.AddCommand(showCommand)
+	commandDefintion.AddCommand(checkCommand)
 	cmd.Root.AddCommand(commandDefintion)
 }
 
@@ -18,3 +24,42 @@ var commandDefintion = &cobra.Command{
 		fs.EditConfig()
 	},
 }
+
+var showCommand = &cobra.Command{
+	Use:   "show [remote]",
+	Short: `Dump the merged effective config as YAML.`,
+	Long: `
+Dump the merged effective config - the INI file, any --config-file,
+and any RCLONE_<REMOTE>_<KEY> env overlay - as YAML. Pass a remote
+name to show just that one.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(0, 1, command, args)
+		effective := fs.EffectiveConfig()
+		if len(args) == 1 {
+			settings, ok := effective[args[0]]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "no such remote %q\n", args[0])
+				os.Exit(1)
+			}
+			effective = map[string]map[string]string{args[0]: settings}
+		}
+		out, err := yaml.Marshal(effective)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to marshal config: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(out)
+	},
+}
+
+var checkCommand = &cobra.Command{
+	Use:   "check",
+	Short: `Validate that every configured remote has the settings its type needs.`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(0, 0, command, args)
+		cmd.Run(false, false, command, func() error {
+			return fs.CheckConfig()
+		})
+	},
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"log"
+	"os"
+
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+	"github.com/spf13/cobra"
+)
+
+// ConfigFile is set by --config-file (or RCLONE_CONFIG_FILE) to a YAML
+// or JSON file of remote settings, merged into the config store before
+// any command runs so rclone can be driven from CI/containers without
+// the interactive `config` editor.
+var ConfigFile = os.Getenv("RCLONE_CONFIG_FILE")
+
+func init() {
+	Root.PersistentFlags().StringVar(&ConfigFile, "config-file", ConfigFile, "YAML or JSON file of remote settings to merge into the config")
+	cobra.OnInitialize(loadConfigFile)
+}
+
+// loadConfigFile merges --config-file (if any) and then the
+// RCLONE_<REMOTE>_<KEY> env overlay into the config store, before the
+// command's own Run executes
+func loadConfigFile() {
+	if ConfigFile != "" {
+		if err := fs.LoadConfigFile(ConfigFile); err != nil {
+			log.Fatalf("Failed to load --config-file %q: %v", ConfigFile, err)
+		}
+	}
+	fs.OverlayConfigEnv()
+}
@@ -0,0 +1,75 @@
+package dedupe
+
+import (
+	"fmt"
+
+	"github.com/rmdashrf/rclone_acd_hack/cmd"
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+	"github.com/spf13/cobra"
+)
+
+// dedupeMode is set by the --dedupe-mode flag
+var dedupeMode = "interactive"
+
+func init() {
+	commandDefintion.Flags().StringVarP(&dedupeMode, "dedupe-mode", "", dedupeMode, "Dedupe mode interactive|skip|first|newest|oldest|rename|largest|smallest|hash.")
+	cmd.Root.AddCommand(commandDefintion)
+}
+
+var commandDefintion = &cobra.Command{
+	Use:   "dedupe remote:path",
+	Short: `Interactively find duplicate files and delete/rename them.`,
+	Long: `
+By default "dedupe" interactively finds duplicate files and offers to
+delete all but one or rename them to be unique. Use --dedupe-mode to
+choose a rule instead of the interactive prompt:
+
+  - skip - removes identical files then skips anything left
+  - first - removes identical files then keeps the first one
+  - newest - removes identical files then keeps the newest one
+  - oldest - removes identical files then keeps the oldest one
+  - rename - removes identical files then renames the rest to be unique
+  - largest - removes identical files then keeps the largest one
+  - smallest - removes identical files then keeps the smallest one
+  - hash - groups objects across the whole remote by content hash (not
+    just by name) and keeps the lexicographically first path in each
+    group, which catches cross-directory duplicates the other modes miss
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		fdst := cmd.NewFsSubdir(cmd.NewFsSrc(args))
+		mode, err := parseMode(dedupeMode)
+		if err != nil {
+			fs.Stats.Error()
+			fmt.Println(err)
+			return
+		}
+		cmd.Run(false, true, command, func() error {
+			return fs.Deduplicate(fdst, mode)
+		})
+	},
+}
+
+func parseMode(name string) (fs.DeduplicateMode, error) {
+	switch name {
+	case "interactive":
+		return fs.DeduplicateInteractive, nil
+	case "skip":
+		return fs.DeduplicateSkip, nil
+	case "first":
+		return fs.DeduplicateFirst, nil
+	case "newest":
+		return fs.DeduplicateNewest, nil
+	case "oldest":
+		return fs.DeduplicateOldest, nil
+	case "rename":
+		return fs.DeduplicateRename, nil
+	case "largest":
+		return fs.DeduplicateLargest, nil
+	case "smallest":
+		return fs.DeduplicateSmallest, nil
+	case "hash":
+		return fs.DeduplicateByHash, nil
+	}
+	return fs.DeduplicateInteractive, fmt.Errorf("unknown mode %q for --dedupe-mode", name)
+}
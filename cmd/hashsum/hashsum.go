@@ -0,0 +1,37 @@
+package hashsum
+
+import (
+	"os"
+	"strings"
+
+	"github.com/rmdashrf/rclone_acd_hack/cmd"
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefintion)
+}
+
+var commandDefintion = &cobra.Command{
+	Use:   "hashsum <algo> remote:path",
+	Short: `Produces a hashsum file for all the objects in the path.`,
+	Long: `
+Produces a hash file for all the objects in the path using the
+supplied hash algorithm. This is in the same format as the standard
+md5sum/sha1sum/sha256sum tools produce.
+
+Supported algorithms are md5, sha1, sha256, sha512 and blake2b-256.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		ht, err := fs.ParseHashType(strings.ToLower(args[0]))
+		cmd.Run(false, false, command, func() error {
+			if err != nil {
+				return err
+			}
+			fsrc := cmd.NewFsSubdir(cmd.NewFsSrc(args[1:]))
+			return fs.HashSum(fsrc, ht, os.Stdout)
+		})
+	},
+}
@@ -17,7 +17,7 @@ var commandDefintion = &cobra.Command{
 	Short: `List all directories/containers/buckets in the path.`,
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(1, 1, command, args)
-		fsrc := cmd.NewFsSrc(args)
+		fsrc := cmd.NewFsSubdir(cmd.NewFsSrc(args))
 		cmd.Run(false, false, command, func() error {
 			return fs.ListDir(fsrc, os.Stdout)
 		})
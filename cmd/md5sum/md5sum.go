@@ -21,9 +21,9 @@ is in the same format as the standard md5sum tool produces.
 `,
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(1, 1, command, args)
-		fsrc := cmd.NewFsSrc(args)
+		fsrc := cmd.NewFsSubdir(cmd.NewFsSrc(args))
 		cmd.Run(false, false, command, func() error {
-			return fs.Md5sum(fsrc, os.Stdout)
+			return fs.HashSum(fsrc, fs.HashMD5, os.Stdout)
 		})
 	},
 }
@@ -1,7 +1,10 @@
 package obscure
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/rmdashrf/rclone_acd_hack/cmd"
 	"github.com/rmdashrf/rclone_acd_hack/fs"
@@ -15,10 +18,25 @@ func init() {
 var commandDefintion = &cobra.Command{
 	Use:   "obscure password",
 	Short: `Obscure password for use in the rclone.conf`,
+	Long: `
+Obscure password for use in the rclone.conf
+
+Pass "-" instead of a password to read it from stdin, which avoids
+exposing it via the process command line (visible in /proc/*/cmdline
+or ps output) when piping it in from a secret manager.
+`,
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(1, 1, command, args)
 		cmd.Run(false, false, command, func() error {
-			obscure := fs.MustObscure(args[0])
+			password := args[0]
+			if password == "-" {
+				scanner := bufio.NewScanner(os.Stdin)
+				if !scanner.Scan() {
+					return fmt.Errorf("failed to read password from stdin: %v", scanner.Err())
+				}
+				password = strings.TrimRight(scanner.Text(), "\r\n")
+			}
+			obscure := fs.MustObscure(password)
 			fmt.Println(obscure)
 			return nil
 		})
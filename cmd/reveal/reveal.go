@@ -0,0 +1,76 @@
+package reveal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rmdashrf/rclone_acd_hack/cmd"
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+	"github.com/spf13/cobra"
+)
+
+// iKnowWhatImDoing gates reveal so the plaintext password isn't printed by accident
+var iKnowWhatImDoing bool
+
+func init() {
+	commandDefintion.Flags().BoolVar(&iKnowWhatImDoing, "i-know-what-im-doing", false, "Skip the confirmation prompt and print the revealed password")
+	cmd.Root.AddCommand(commandDefintion)
+}
+
+var commandDefintion = &cobra.Command{
+	Use:   "reveal password",
+	Short: `Reveal an obscured password for use in scripts or debugging.`,
+	Long: `
+Reveal an obscured password, the opposite of the "obscure" command.
+
+Pass "-" instead of an obscured string to read it from stdin. Since
+the output is a plaintext password, this command refuses to run
+unless --i-know-what-im-doing is passed or the user confirms
+interactively, to avoid accidentally leaking the password into shell
+history or CI logs.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		cmd.Run(false, false, command, func() error {
+			if !iKnowWhatImDoing && !confirm() {
+				return fmt.Errorf("not confirmed, not revealing password")
+			}
+			obscured := args[0]
+			if obscured == "-" {
+				scanner := bufio.NewScanner(os.Stdin)
+				if !scanner.Scan() {
+					return fmt.Errorf("failed to read obscured password from stdin: %v", scanner.Err())
+				}
+				obscured = strings.TrimRight(scanner.Text(), "\r\n")
+			}
+			revealed, err := fs.Reveal(obscured)
+			if err != nil {
+				return err
+			}
+			fmt.Println(revealed)
+			return nil
+		})
+	},
+}
+
+// confirm asks the user to answer "y" or "yes" before printing a plaintext
+// password. The answer is read from /dev/tty rather than os.Stdin,
+// since the caller may be piping the obscured value itself through
+// stdin via the "-" argument - sharing stdin between the two would
+// consume the piped value as the confirmation answer instead.
+func confirm() bool {
+	fmt.Fprintf(os.Stderr, "This will print the plaintext password to stdout. Continue? (y/n) ")
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+	scanner := bufio.NewScanner(tty)
+	if !scanner.Scan() {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
+	return answer == "y" || answer == "yes"
+}
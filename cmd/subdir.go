@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+)
+
+// Subdir is set by the --subdir flag to scope a command to a subtree of
+// the remote without having to touch the underlying backend
+var Subdir string
+
+func init() {
+	Root.PersistentFlags().StringVar(&Subdir, "subdir", "", "Scope the command to this subdirectory of remote:path")
+}
+
+// NewFsSubdir wraps f in fs.NewSubFs when --subdir was passed, otherwise
+// returns f unchanged
+func NewFsSubdir(f fs.Fs) fs.Fs {
+	if Subdir == "" {
+		return f
+	}
+	sub, err := fs.NewSubFs(f, Subdir)
+	if err != nil {
+		log.Printf("Failed to scope %v to --subdir %q: %v", f, Subdir, err)
+		return f
+	}
+	return sub
+}
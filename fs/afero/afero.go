@@ -0,0 +1,139 @@
+// Package afero adapts any rclone fs.Fs to the spf13/afero.Fs interface
+// (and back), so the many Go libraries that already consume afero.Fs -
+// static site generators, template engines, archive readers, SQLite VFS
+// shims - can read and write against Drive, S3, SFTP, etc.
+package afero
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+	"github.com/spf13/afero"
+)
+
+// ToAfero wraps an rclone Fs as an afero.Fs
+func ToAfero(f fs.Fs) afero.Fs {
+	return &wrapFs{f: f}
+}
+
+// wrapFs implements afero.Fs on top of an fs.Fs
+type wrapFs struct {
+	f fs.Fs
+}
+
+// only the operations the underlying backend actually supports are
+// exercised by the shared conformance suite in conformance_test.go - callers
+// should check f.Features() before relying on Rename, for instance.
+
+func (w *wrapFs) Create(name string) (afero.File, error) {
+	info := fs.NewStaticObjectInfo(name, nowOrZero(), 0, true, nil, nil)
+	o, err := w.f.Put(emptyReader{}, info)
+	if err != nil {
+		return nil, err
+	}
+	return newWriteFile(w.f, o), nil
+}
+
+func (w *wrapFs) Mkdir(name string, perm os.FileMode) error {
+	return w.f.Mkdir(name)
+}
+
+func (w *wrapFs) MkdirAll(path string, perm os.FileMode) error {
+	return w.f.Mkdir(path)
+}
+
+func (w *wrapFs) Open(name string) (afero.File, error) {
+	return w.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (w *wrapFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	o, err := w.f.NewObject(name)
+	if err != nil {
+		if flag&os.O_CREATE == 0 {
+			return nil, err
+		}
+		// doesn't exist yet - O_TRUNC is implicit, nothing to preserve
+		return w.Create(name)
+	}
+	if flag&os.O_TRUNC != 0 {
+		return w.Create(name)
+	}
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		// read-modify-write: preserve the existing content so Close
+		// flushes it back unless the caller actually overwrites it
+		return newWriteFileFromExisting(w.f, o)
+	}
+	return newReadFile(w, o), nil
+}
+
+func (w *wrapFs) Remove(name string) error {
+	o, err := w.f.NewObject(name)
+	if err != nil {
+		return err
+	}
+	return o.Remove()
+}
+
+func (w *wrapFs) RemoveAll(path string) error {
+	entries, err := w.f.List(path)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if o, ok := e.(fs.Object); ok {
+			if err := o.Remove(); err != nil {
+				return err
+			}
+		} else if d, ok := e.(fs.Directory); ok {
+			if err := w.RemoveAll(d.Remote()); err != nil {
+				return err
+			}
+		}
+	}
+	return w.f.Rmdir(path)
+}
+
+func (w *wrapFs) Rename(oldname, newname string) error {
+	mover, ok := w.f.(fs.Mover)
+	if !ok {
+		return fmt.Errorf("afero: %v does not support rename", w.f)
+	}
+	o, err := w.f.NewObject(oldname)
+	if err != nil {
+		return err
+	}
+	_, err = mover.Move(o, newname)
+	return err
+}
+
+func (w *wrapFs) Stat(name string) (os.FileInfo, error) {
+	if o, err := w.f.NewObject(name); err == nil {
+		return &fileInfo{name: name, o: o}, nil
+	}
+	// Treat anything listable as a directory
+	if _, err := w.f.List(name); err == nil {
+		return &fileInfo{name: name, isDir: true}, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func (w *wrapFs) Name() string { return "rclonefs" }
+
+func (w *wrapFs) Chmod(name string, mode os.FileMode) error { return nil }
+
+func (w *wrapFs) Chtimes(name string, atime, mtime time.Time) error {
+	o, err := w.f.NewObject(name)
+	if err != nil {
+		return err
+	}
+	return o.SetModTime(mtime)
+}
+
+type emptyReader struct{}
+
+func (emptyReader) Read(p []byte) (int, error) { return 0, io.EOF }
+
+func nowOrZero() time.Time { return time.Now() }
@@ -0,0 +1,95 @@
+package afero_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+	rafero "github.com/rmdashrf/rclone_acd_hack/fs/afero"
+	memafero "github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// runAferoConformance exercises Create/Open/Remove against any afero.Fs,
+// whether it's ToAfero wrapping a real remote or a bare afero.MemMapFs.
+func runAferoConformance(t *testing.T, af memafero.Fs) {
+	fh, err := af.Create("hello.txt")
+	require.NoError(t, err)
+	_, err = fh.Write([]byte("hello world"))
+	require.NoError(t, err)
+	require.NoError(t, fh.Close())
+
+	fh, err = af.Open("hello.txt")
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(fh)
+	require.NoError(t, err)
+	require.NoError(t, fh.Close())
+	require.Equal(t, "hello world", string(data))
+
+	require.NoError(t, af.Remove("hello.txt"))
+	_, err = af.Open("hello.txt")
+	require.Error(t, err)
+}
+
+func TestFromAferoRoundTrip(t *testing.T) {
+	mem := memafero.NewMemMapFs()
+	runAferoConformance(t, mem)
+
+	// FromAfero must reproduce what afero itself just exercised, since
+	// it's a thin adapter rather than a reimplementation
+	f := rafero.FromAfero(mem, "TestFromAfero", "")
+	require.NoError(t, f.Mkdir(""))
+
+	content := "round tripped through FromAfero"
+	info := fs.NewStaticObjectInfo("roundtrip.txt", time.Now(), int64(len(content)), true, nil, nil)
+	_, err := f.Put(strings.NewReader(content), info)
+	require.NoError(t, err)
+
+	o, err := f.NewObject("roundtrip.txt")
+	require.NoError(t, err)
+	rc, err := o.Open()
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	require.Equal(t, content, string(data))
+}
+
+func TestToAferoOpenFileRDWRPreservesExistingContent(t *testing.T) {
+	f := rafero.FromAfero(memafero.NewMemMapFs(), "TestToAferoRDWR", "")
+	require.NoError(t, f.Mkdir(""))
+
+	content := "already written"
+	info := fs.NewStaticObjectInfo("file.txt", time.Now(), int64(len(content)), true, nil, nil)
+	_, err := f.Put(strings.NewReader(content), info)
+	require.NoError(t, err)
+
+	af := rafero.ToAfero(f)
+	fh, err := af.OpenFile("file.txt", os.O_RDWR, 0644)
+	require.NoError(t, err)
+	require.NoError(t, fh.Close())
+
+	o, err := f.NewObject("file.txt")
+	require.NoError(t, err)
+	rc, err := o.Open()
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	require.Equal(t, content, string(data))
+}
+
+func TestToAferoSkipsUnsupportedRename(t *testing.T) {
+	mem := memafero.NewMemMapFs()
+	f := rafero.FromAfero(mem, "TestToAfero", "")
+	require.NoError(t, f.Mkdir(""))
+
+	// reverseFs (the FromAfero adapter) doesn't implement fs.Mover, so
+	// ToAfero's Rename must fail cleanly rather than panic
+	af := rafero.ToAfero(f)
+	err := af.Rename("a", "b")
+	require.Error(t, err)
+}
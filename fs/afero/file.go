@@ -0,0 +1,219 @@
+package afero
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+	"github.com/spf13/afero"
+)
+
+// fileInfo implements os.FileInfo over an fs.Object (or a bare directory
+// remote, where o is nil)
+type fileInfo struct {
+	name  string
+	o     fs.Object
+	isDir bool
+}
+
+func (i *fileInfo) Name() string { return path.Base(i.name) }
+func (i *fileInfo) Size() int64 {
+	if i.o == nil {
+		return 0
+	}
+	return i.o.Size()
+}
+func (i *fileInfo) Mode() os.FileMode {
+	if i.isDir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+func (i *fileInfo) ModTime() time.Time {
+	if i.o == nil {
+		return time.Time{}
+	}
+	return i.o.ModTime()
+}
+func (i *fileInfo) IsDir() bool      { return i.isDir }
+func (i *fileInfo) Sys() interface{} { return i.o }
+
+// readFile implements afero.File for reading an existing Object. Readdir
+// lists the directory once with ListDirSorted and then hands entries out
+// from a cursor across calls; the whole listing is held in memory.
+type readFile struct {
+	w      *wrapFs
+	o      fs.Object
+	rc     io.ReadCloser
+	cursor int
+	dirBuf fs.DirEntries
+}
+
+func newReadFile(w *wrapFs, o fs.Object) *readFile {
+	return &readFile{w: w, o: o}
+}
+
+func (f *readFile) ensureOpen() error {
+	if f.rc != nil {
+		return nil
+	}
+	rc, err := f.o.Open()
+	if err != nil {
+		return err
+	}
+	f.rc = rc
+	return nil
+}
+
+func (f *readFile) Read(p []byte) (int, error) {
+	if err := f.ensureOpen(); err != nil {
+		return 0, err
+	}
+	return f.rc.Read(p)
+}
+
+func (f *readFile) ReadAt(p []byte, off int64) (int, error) {
+	return 0, fmt.Errorf("afero: ReadAt not supported, use Seek+Read")
+}
+
+func (f *readFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, fmt.Errorf("afero: Seek not supported on a streamed remote object")
+}
+
+func (f *readFile) Write(p []byte) (int, error) { return 0, fmt.Errorf("afero: file opened read-only") }
+func (f *readFile) WriteAt(p []byte, off int64) (int, error) {
+	return 0, fmt.Errorf("afero: file opened read-only")
+}
+func (f *readFile) WriteString(s string) (int, error) { return f.Write([]byte(s)) }
+
+func (f *readFile) Close() error {
+	if f.rc == nil {
+		return nil
+	}
+	return f.rc.Close()
+}
+
+func (f *readFile) Name() string { return f.o.Remote() }
+
+func (f *readFile) Readdir(count int) ([]os.FileInfo, error) {
+	if f.dirBuf == nil {
+		entries, err := fs.ListDirSorted(f.w.f, true, f.o.Remote())
+		if err != nil {
+			return nil, err
+		}
+		f.dirBuf = entries
+	}
+	var out []os.FileInfo
+	for count <= 0 || len(out) < count {
+		if f.cursor >= len(f.dirBuf) {
+			if count <= 0 {
+				break
+			}
+			if len(out) == 0 {
+				return nil, io.EOF
+			}
+			break
+		}
+		e := f.dirBuf[f.cursor]
+		f.cursor++
+		switch v := e.(type) {
+		case fs.Object:
+			out = append(out, &fileInfo{name: v.Remote(), o: v})
+		case fs.Directory:
+			out = append(out, &fileInfo{name: v.Remote(), isDir: true})
+		}
+	}
+	return out, nil
+}
+
+func (f *readFile) Readdirnames(n int) ([]string, error) {
+	infos, err := f.Readdir(n)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(infos))
+	for i, fi := range infos {
+		names[i] = fi.Name()
+	}
+	return names, nil
+}
+
+func (f *readFile) Stat() (os.FileInfo, error) { return &fileInfo{name: f.o.Remote(), o: f.o}, nil }
+func (f *readFile) Sync() error                { return nil }
+func (f *readFile) Truncate(size int64) error  { return fmt.Errorf("afero: file opened read-only") }
+
+// writeFile buffers writes in memory and flushes them with a single Put
+// on Close, since most rclone backends need to know the final size up
+// front
+type writeFile struct {
+	f   fs.Fs
+	o   fs.Object
+	buf bytes.Buffer
+}
+
+func newWriteFile(f fs.Fs, o fs.Object) *writeFile {
+	return &writeFile{f: f, o: o}
+}
+
+// newWriteFileFromExisting opens o for read-modify-write, preloading its
+// current content into the write buffer so Close flushes it back
+// unchanged unless the caller actually overwrites or truncates it - this
+// is what keeps an O_RDWR-without-O_TRUNC open from destroying the
+// object's content before the caller has written anything
+func newWriteFileFromExisting(f fs.Fs, o fs.Object) (*writeFile, error) {
+	rc, err := o.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	wf := &writeFile{f: f, o: o}
+	if _, err := io.Copy(&wf.buf, rc); err != nil {
+		return nil, fmt.Errorf("afero: failed to preload existing content: %w", err)
+	}
+	return wf, nil
+}
+
+func (f *writeFile) Read(p []byte) (int, error) {
+	return 0, fmt.Errorf("afero: file opened write-only")
+}
+func (f *writeFile) ReadAt(p []byte, off int64) (int, error) {
+	return 0, fmt.Errorf("afero: file opened write-only")
+}
+func (f *writeFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, fmt.Errorf("afero: Seek not supported while writing")
+}
+
+func (f *writeFile) Write(p []byte) (int, error) { return f.buf.Write(p) }
+func (f *writeFile) WriteAt(p []byte, off int64) (int, error) {
+	return 0, fmt.Errorf("afero: WriteAt not supported, write sequentially")
+}
+func (f *writeFile) WriteString(s string) (int, error) { return f.buf.WriteString(s) }
+
+func (f *writeFile) Close() error {
+	info := fs.NewStaticObjectInfo(f.o.Remote(), time.Now(), int64(f.buf.Len()), true, nil, nil)
+	_, err := f.f.Put(bytes.NewReader(f.buf.Bytes()), info)
+	return err
+}
+
+func (f *writeFile) Name() string { return f.o.Remote() }
+func (f *writeFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, fmt.Errorf("afero: Readdir not supported on a file")
+}
+func (f *writeFile) Readdirnames(n int) ([]string, error) {
+	return nil, fmt.Errorf("afero: Readdirnames not supported on a file")
+}
+func (f *writeFile) Stat() (os.FileInfo, error) { return &fileInfo{name: f.o.Remote(), o: f.o}, nil }
+func (f *writeFile) Sync() error                { return nil }
+func (f *writeFile) Truncate(size int64) error  { f.buf.Reset(); return nil }
+
+// Check the interfaces are satisfied
+var (
+	_ afero.File  = (*readFile)(nil)
+	_ afero.File  = (*writeFile)(nil)
+	_ os.FileInfo = (*fileInfo)(nil)
+)
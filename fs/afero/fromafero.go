@@ -0,0 +1,128 @@
+package afero
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+	"github.com/spf13/afero"
+)
+
+// FromAfero turns an afero.Fs (for instance an in-memory afero.MemMapFs)
+// into a valid rclone fs.Fs, so tests can stand up a backend without
+// the usual NewRun(t) scaffolding against a real remote.
+func FromAfero(af afero.Fs, name, root string) fs.Fs {
+	f := &reverseFs{af: af, name: name, root: root}
+	f.features = (&fs.Features{
+		CanHaveEmptyDirectories: true,
+	}).Fill(f)
+	return f
+}
+
+type reverseFs struct {
+	af       afero.Fs
+	name     string
+	root     string
+	features *fs.Features
+}
+
+type reverseObject struct {
+	f       *reverseFs
+	remote  string
+	size    int64
+	modTime time.Time
+}
+
+func (f *reverseFs) Name() string             { return f.name }
+func (f *reverseFs) Root() string             { return f.root }
+func (f *reverseFs) String() string           { return fmt.Sprintf("afero:%s", f.name) }
+func (f *reverseFs) Precision() time.Duration { return time.Second }
+func (f *reverseFs) Hashes() fs.HashSet       { return fs.HashSet(fs.HashNone) }
+func (f *reverseFs) Features() *fs.Features   { return f.features }
+
+func (f *reverseFs) List(dir string) (fs.DirEntries, error) {
+	fh, err := f.af.Open(dir)
+	if err != nil {
+		return nil, fs.ErrorDirNotFound
+	}
+	defer fh.Close()
+	infos, err := fh.Readdir(-1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	var entries fs.DirEntries
+	for _, fi := range infos {
+		remote := dir + "/" + fi.Name()
+		if dir == "" {
+			remote = fi.Name()
+		}
+		if fi.IsDir() {
+			entries = append(entries, fs.NewDir(remote, fi.ModTime()))
+		} else {
+			entries = append(entries, &reverseObject{f: f, remote: remote, size: fi.Size(), modTime: fi.ModTime()})
+		}
+	}
+	return entries, nil
+}
+
+func (f *reverseFs) NewObject(remote string) (fs.Object, error) {
+	fi, err := f.af.Stat(remote)
+	if err != nil {
+		return nil, fs.ErrorObjectNotFound
+	}
+	if fi.IsDir() {
+		return nil, fs.ErrorIsDir
+	}
+	return &reverseObject{f: f, remote: remote, size: fi.Size(), modTime: fi.ModTime()}, nil
+}
+
+func (f *reverseFs) Put(in io.Reader, src fs.ObjectInfo) (fs.Object, error) {
+	o := &reverseObject{f: f, remote: src.Remote()}
+	return o, o.Update(in, src)
+}
+
+func (f *reverseFs) Mkdir(dir string) error { return f.af.MkdirAll(dir, 0755) }
+func (f *reverseFs) Rmdir(dir string) error { return f.af.Remove(dir) }
+
+func (o *reverseObject) Fs() fs.Info                      { return o.f }
+func (o *reverseObject) Remote() string                   { return o.remote }
+func (o *reverseObject) String() string                   { return o.remote }
+func (o *reverseObject) Size() int64                      { return o.size }
+func (o *reverseObject) ModTime() time.Time               { return o.modTime }
+func (o *reverseObject) Hash(fs.HashType) (string, error) { return "", fs.ErrHashUnsupported }
+func (o *reverseObject) Storable() bool                   { return true }
+
+func (o *reverseObject) SetModTime(t time.Time) error {
+	o.modTime = t
+	return o.f.af.Chtimes(o.remote, t, t)
+}
+
+func (o *reverseObject) Open(options ...fs.OpenOption) (io.ReadCloser, error) {
+	return o.f.af.Open(o.remote)
+}
+
+func (o *reverseObject) Update(in io.Reader, src fs.ObjectInfo) error {
+	fh, err := o.f.af.Create(o.remote)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(fh, in)
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	o.size = n
+	o.modTime = src.ModTime()
+	return o.f.af.Chtimes(o.remote, o.modTime, o.modTime)
+}
+
+func (o *reverseObject) Remove() error { return o.f.af.Remove(o.remote) }
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs     = (*reverseFs)(nil)
+	_ fs.Object = (*reverseObject)(nil)
+)
@@ -4,9 +4,12 @@ import (
 	// Active file systems
 	_ "github.com/rmdashrf/rclone_acd_hack/amazonclouddrive"
 	_ "github.com/rmdashrf/rclone_acd_hack/b2"
+	_ "github.com/rmdashrf/rclone_acd_hack/cas"
 	_ "github.com/rmdashrf/rclone_acd_hack/crypt"
 	_ "github.com/rmdashrf/rclone_acd_hack/drive"
 	_ "github.com/rmdashrf/rclone_acd_hack/dropbox"
+	_ "github.com/rmdashrf/rclone_acd_hack/fs/cache"
+	_ "github.com/rmdashrf/rclone_acd_hack/fs/overlay"
 	_ "github.com/rmdashrf/rclone_acd_hack/ftp"
 	_ "github.com/rmdashrf/rclone_acd_hack/googlecloudstorage"
 	_ "github.com/rmdashrf/rclone_acd_hack/hubic"
@@ -14,6 +17,7 @@ import (
 	_ "github.com/rmdashrf/rclone_acd_hack/onedrive"
 	_ "github.com/rmdashrf/rclone_acd_hack/s3"
 	_ "github.com/rmdashrf/rclone_acd_hack/sftp"
+	_ "github.com/rmdashrf/rclone_acd_hack/smb"
 	_ "github.com/rmdashrf/rclone_acd_hack/swift"
 	_ "github.com/rmdashrf/rclone_acd_hack/yandex"
 )
@@ -0,0 +1,495 @@
+// Package cache wraps any Fs with a read-through, TTL and size bounded
+// local disk cache so repeated reads of the same object don't round-trip
+// to the backing remote.
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+)
+
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name:        "cache",
+		Description: "Read-through disk cache over another remote",
+		NewFs:       NewFs,
+		Options: []fs.Option{{
+			Name: "remote",
+			Help: "Remote to cache, e.g. \"remote:path\".",
+		}, {
+			Name: "cache_dir",
+			Help: "Local directory to hold cached object data and the metadata database.",
+		}, {
+			Name:    "cache_ttl",
+			Help:    "How long cached listings and metadata remain valid for, e.g. \"1h\".",
+			Default: fs.Duration(time.Hour),
+		}, {
+			Name:    "cache_max_size",
+			Help:    "Maximum size of the cached data in cache_dir before the oldest cached files, by the time they were cached, are evicted. Reading a cached file does not change its place in that order.",
+			Default: fs.SizeSuffix(10 << 30), // 10GiB
+		}},
+	})
+}
+
+// Options for this backend
+type Options struct {
+	Remote       string        `config:"remote"`
+	CacheDir     string        `config:"cache_dir"`
+	CacheTTL     fs.Duration   `config:"cache_ttl"`
+	CacheMaxSize fs.SizeSuffix `config:"cache_max_size"`
+}
+
+// Fs caches reads from a wrapped Fs on local disk
+type Fs struct {
+	name     string
+	root     string
+	opt      Options
+	wrapped  fs.Fs
+	features *fs.Features
+
+	dataDir string
+	db      *bolt.DB
+
+	mu           sync.Mutex
+	curSize      int64
+	backendReads int64 // exposed for tests: counts reads that hit the wrapped Fs
+	backendLists int64 // exposed for tests: counts List calls that miss the listing cache
+}
+
+// metaBucket is the bolt bucket listings/object metadata is kept in
+var metaBucket = []byte("meta")
+
+// Object is a cached view of an object on the wrapped remote
+type Object struct {
+	f       *Fs
+	remote  string
+	size    int64
+	modTime time.Time
+	hash    string
+}
+
+// entryKey is how an object is keyed in the local cache: its remote path,
+// modtime, size and hash all have to match for a cached copy to be served
+func entryKey(remote string, modTime time.Time, size int64, hash string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d|%s", remote, modTime.UnixNano(), size, hash)))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewFs constructs a caching Fs wrapping opt.Remote
+func NewFs(name, root string) (fs.Fs, error) {
+	opt := new(Options)
+	if err := fs.ConfigFileGetStruct(name, opt); err != nil {
+		return nil, err
+	}
+	wrapped, err := fs.NewFs(opt.Remote)
+	if err != nil {
+		return nil, fmt.Errorf("cache: failed to make wrapped remote %q: %w", opt.Remote, err)
+	}
+	if opt.CacheDir == "" {
+		return nil, fmt.Errorf("cache: cache_dir must be set")
+	}
+	if err := os.MkdirAll(opt.CacheDir, 0700); err != nil {
+		return nil, fmt.Errorf("cache: failed to create cache_dir: %w", err)
+	}
+
+	db, err := bolt.Open(filepath.Join(opt.CacheDir, "cache.db"), 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cache: failed to open metadata db: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(metaBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cache: failed to init metadata db: %w", err)
+	}
+
+	f := &Fs{
+		name:    name,
+		root:    root,
+		opt:     *opt,
+		wrapped: wrapped,
+		dataDir: filepath.Join(opt.CacheDir, "data"),
+		db:      db,
+	}
+	if err := os.MkdirAll(f.dataDir, 0700); err != nil {
+		return nil, fmt.Errorf("cache: failed to create data dir: %w", err)
+	}
+	size, err := dirSize(f.dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("cache: failed to measure cache_dir: %w", err)
+	}
+	f.curSize = size
+	f.features = wrapped.Features().Fill(f)
+	return f, nil
+}
+
+// dirSize sums the size of every regular file directly inside dir, used
+// to seed curSize from whatever a previous run already cached
+func dirSize(dir string) (int64, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, fi := range entries {
+		if !fi.IsDir() {
+			total += fi.Size()
+		}
+	}
+	return total, nil
+}
+
+// Name of the remote
+func (f *Fs) Name() string { return f.name }
+
+// Root of the remote
+func (f *Fs) Root() string { return f.root }
+
+// String converts this Fs to a string
+func (f *Fs) String() string { return fmt.Sprintf("cache:%s", f.wrapped.String()) }
+
+// Precision passed through from the wrapped Fs
+func (f *Fs) Precision() time.Duration { return f.wrapped.Precision() }
+
+// Hashes passed through from the wrapped Fs
+func (f *Fs) Hashes() fs.HashSet { return f.wrapped.Hashes() }
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features { return f.features }
+
+// BackendReads returns the number of reads that have gone through to the
+// wrapped Fs since this cache Fs was created - used by tests to verify a
+// second read is served entirely from the local cache
+func (f *Fs) BackendReads() int64 { return atomic.LoadInt64(&f.backendReads) }
+
+// BackendLists returns the number of List calls that missed the listing
+// cache and went through to the wrapped Fs since this cache Fs was
+// created - used by tests to verify a second List within cache_ttl is
+// served entirely from the local cache
+func (f *Fs) BackendLists() int64 { return atomic.LoadInt64(&f.backendLists) }
+
+// List caches listings for cache_ttl, keyed by dir
+func (f *Fs) List(dir string) (fs.DirEntries, error) {
+	key := []byte("list:" + dir)
+	if entries, ok := f.getListing(key); ok {
+		return entries, nil
+	}
+	atomic.AddInt64(&f.backendLists, 1)
+	entries, err := f.wrapped.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	f.putListing(key, entries)
+	return entries, nil
+}
+
+// listEntry is the gob-serializable form of one fs.DirEntry in a cached
+// listing - entries are reconstructed directly from these fields rather
+// than by re-querying the wrapped Fs, which is the whole point of
+// caching a listing
+type listEntry struct {
+	Remote  string
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+	Hash    string
+}
+
+// cachedListing is gob-encoded into the metadata db under a "list:" key
+type cachedListing struct {
+	Time    time.Time
+	Entries []listEntry
+}
+
+func (f *Fs) getListing(key []byte) (fs.DirEntries, bool) {
+	var raw []byte
+	_ = f.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(metaBucket).Get(key); v != nil {
+			raw = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	if raw == nil {
+		return nil, false
+	}
+
+	var cached cachedListing
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(&cached); err != nil {
+		return nil, false
+	}
+	if time.Since(cached.Time) > time.Duration(f.opt.CacheTTL) {
+		return nil, false
+	}
+
+	entries := make(fs.DirEntries, len(cached.Entries))
+	for i, e := range cached.Entries {
+		if e.IsDir {
+			entries[i] = fs.NewDir(e.Remote, e.ModTime)
+			continue
+		}
+		entries[i] = &Object{f: f, remote: e.Remote, size: e.Size, modTime: e.ModTime, hash: e.Hash}
+	}
+	return entries, true
+}
+
+func (f *Fs) putListing(key []byte, entries fs.DirEntries) {
+	cached := cachedListing{Time: time.Now()}
+	for _, e := range entries {
+		switch v := e.(type) {
+		case fs.Object:
+			hash, _ := v.Hash(f.wrapped.Hashes().GetOne())
+			cached.Entries = append(cached.Entries, listEntry{
+				Remote: v.Remote(), Size: v.Size(), ModTime: v.ModTime(), Hash: hash,
+			})
+		case fs.Directory:
+			cached.Entries = append(cached.Entries, listEntry{Remote: v.Remote(), IsDir: true, ModTime: v.ModTime()})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(cached); err != nil {
+		return
+	}
+	_ = f.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(key, buf.Bytes())
+	})
+}
+
+// NewObject looks up remote on the wrapped Fs and wraps it for caching
+func (f *Fs) NewObject(remote string) (fs.Object, error) {
+	o, err := f.wrapped.NewObject(remote)
+	if err != nil {
+		return nil, err
+	}
+	hash, _ := o.Hash(f.wrapped.Hashes().GetOne())
+	return &Object{f: f, remote: remote, size: o.Size(), modTime: o.ModTime(), hash: hash}, nil
+}
+
+// Put writes through to the wrapped Fs and invalidates any cached listing
+// of the directory it landed in
+func (f *Fs) Put(in io.Reader, src fs.ObjectInfo) (fs.Object, error) {
+	o, err := f.wrapped.Put(in, src)
+	if err != nil {
+		return nil, err
+	}
+	f.invalidate(src.Remote())
+	hash, _ := o.Hash(f.wrapped.Hashes().GetOne())
+	return &Object{f: f, remote: o.Remote(), size: o.Size(), modTime: o.ModTime(), hash: hash}, nil
+}
+
+// Mkdir passes through and invalidates the parent listing
+func (f *Fs) Mkdir(dir string) error {
+	if err := f.wrapped.Mkdir(dir); err != nil {
+		return err
+	}
+	f.invalidate(dir)
+	return nil
+}
+
+// Rmdir passes through and invalidates the parent listing
+func (f *Fs) Rmdir(dir string) error {
+	if err := f.wrapped.Rmdir(dir); err != nil {
+		return err
+	}
+	f.invalidate(dir)
+	return nil
+}
+
+// invalidate removes the cached listing of remote's parent directory -
+// the listing that would otherwise still show remote's old state after
+// it's created, changed or removed
+func (f *Fs) invalidate(remote string) {
+	_ = f.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Delete([]byte("list:" + parentDir(remote)))
+	})
+}
+
+// parentDir returns the directory a List of remote's parent would be
+// keyed under, treating the cache root as ""
+func parentDir(remote string) string {
+	dir := path.Dir(remote)
+	if dir == "." || dir == "/" {
+		return ""
+	}
+	return dir
+}
+
+// evictIfNeeded removes the oldest cached data files, by mtime, once
+// curSize exceeds CacheMaxSize
+func (f *Fs) evictIfNeeded(added int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.curSize += added
+	if f.curSize <= int64(f.opt.CacheMaxSize) {
+		return
+	}
+
+	entries, err := ioutil.ReadDir(f.dataDir)
+	if err != nil {
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ModTime().Before(entries[j].ModTime()) })
+
+	for _, fi := range entries {
+		if f.curSize <= int64(f.opt.CacheMaxSize) {
+			break
+		}
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), "tmp-") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(f.dataDir, fi.Name())); err != nil {
+			continue
+		}
+		f.curSize -= fi.Size()
+	}
+}
+
+// Fs returns the parent Fs
+func (o *Object) Fs() fs.Info { return o.f }
+
+// Remote returns the remote path of the object
+func (o *Object) Remote() string { return o.remote }
+
+// String returns a description of the object
+func (o *Object) String() string { return o.remote }
+
+// Size returns the cached size
+func (o *Object) Size() int64 { return o.size }
+
+// ModTime returns the cached modtime
+func (o *Object) ModTime() time.Time { return o.modTime }
+
+// Hash returns the cached hash, falling back to the wrapped object
+func (o *Object) Hash(ht fs.HashType) (string, error) {
+	if o.hash != "" && ht == o.f.wrapped.Hashes().GetOne() {
+		return o.hash, nil
+	}
+	wrapped, err := o.f.wrapped.NewObject(o.remote)
+	if err != nil {
+		return "", err
+	}
+	return wrapped.Hash(ht)
+}
+
+// Storable returns whether this object can be stored
+func (o *Object) Storable() bool { return true }
+
+// SetModTime invalidates the cached copy and passes through
+func (o *Object) SetModTime(t time.Time) error {
+	wrapped, err := o.f.wrapped.NewObject(o.remote)
+	if err != nil {
+		return err
+	}
+	o.f.invalidate(o.remote)
+	o.dropCachedData()
+	return wrapped.SetModTime(t)
+}
+
+// dropCachedData removes o's cached data file, if any, from the local cache
+func (o *Object) dropCachedData() {
+	cachePath := filepath.Join(o.f.dataDir, entryKey(o.remote, o.modTime, o.size, o.hash))
+	if fi, err := os.Stat(cachePath); err == nil && os.Remove(cachePath) == nil {
+		o.f.mu.Lock()
+		o.f.curSize -= fi.Size()
+		o.f.mu.Unlock()
+	}
+}
+
+// Open serves from the local cache file if present and valid, otherwise
+// streams from the wrapped Fs while writing through to the cache
+func (o *Object) Open(options ...fs.OpenOption) (io.ReadCloser, error) {
+	key := entryKey(o.remote, o.modTime, o.size, o.hash)
+	cachePath := filepath.Join(o.f.dataDir, key)
+
+	if fi, err := os.Stat(cachePath); err == nil {
+		if time.Since(fi.ModTime()) < time.Duration(o.f.opt.CacheTTL) {
+			return os.Open(cachePath)
+		}
+	}
+
+	atomic.AddInt64(&o.f.backendReads, 1)
+	wrapped, err := o.f.wrapped.NewObject(o.remote)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := wrapped.Open(options...)
+	if err != nil {
+		return nil, err
+	}
+	return o.teeToCache(rc, cachePath)
+}
+
+// teeToCache streams rc through to a temp file, renaming it into place
+// once fully written, and returns a reader of the freshly cached copy
+func (o *Object) teeToCache(rc io.ReadCloser, cachePath string) (io.ReadCloser, error) {
+	defer rc.Close()
+	tmp, err := ioutil.TempFile(o.f.dataDir, "tmp-")
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(tmp, rc)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("cache: failed to populate cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), cachePath); err != nil {
+		return nil, err
+	}
+	o.f.evictIfNeeded(size)
+	return os.Open(cachePath)
+}
+
+// Update writes through to the wrapped object and drops the stale cache file
+func (o *Object) Update(in io.Reader, src fs.ObjectInfo) error {
+	wrapped, err := o.f.wrapped.NewObject(o.remote)
+	if err != nil {
+		return err
+	}
+	if err := wrapped.Update(in, src); err != nil {
+		return err
+	}
+	o.f.invalidate(o.remote)
+	o.dropCachedData()
+	o.size, o.modTime, o.hash = src.Size(), src.ModTime(), ""
+	return nil
+}
+
+// Remove deletes the wrapped object and any cached data for it
+func (o *Object) Remove() error {
+	wrapped, err := o.f.wrapped.NewObject(o.remote)
+	if err != nil {
+		return err
+	}
+	if err := wrapped.Remove(); err != nil {
+		return err
+	}
+	o.f.invalidate(o.remote)
+	o.dropCachedData()
+	return nil
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs     = (*Fs)(nil)
+	_ fs.Object = (*Object)(nil)
+)
@@ -0,0 +1,159 @@
+package cache_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+	_ "github.com/rmdashrf/rclone_acd_hack/fs/all"
+	"github.com/rmdashrf/rclone_acd_hack/fs/cache"
+	"github.com/stretchr/testify/require"
+)
+
+// The existing TestLs/TestMd5sum/TestCat suites in fs/operations_test.go
+// are reused against this backend by running:
+//
+//	go test ./fs/... -remote cache:realremote:
+//
+// This test covers the thing those suites can't: that a second read of
+// the same object is served entirely from the local cache.
+func TestCacheServesSecondReadFromDisk(t *testing.T) {
+	localDir, err := ioutil.TempDir("", "rclone-cache-backend-test")
+	require.NoError(t, err)
+	cacheDir, err := ioutil.TempDir("", "rclone-cache-dir-test")
+	require.NoError(t, err)
+
+	fs.ConfigFileSet("TestCache", "type", "cache")
+	fs.ConfigFileSet("TestCache", "remote", localDir)
+	fs.ConfigFileSet("TestCache", "cache_dir", cacheDir)
+
+	f, err := fs.NewFs("TestCache:")
+	require.NoError(t, err)
+	cf := f.(*cache.Fs)
+
+	require.NoError(t, f.Mkdir(""))
+	content := "hello from the backing remote"
+	info := fs.NewStaticObjectInfo("file.txt", time.Now(), int64(len(content)), true, nil, nil)
+	_, err = f.Put(strings.NewReader(content), info)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		o, err := f.NewObject("file.txt")
+		require.NoError(t, err)
+		rc, err := o.Open()
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		require.Equal(t, content, string(data))
+	}
+
+	require.EqualValues(t, 1, cf.BackendReads())
+}
+
+// TestCacheServesSecondListFromCache checks that a second List within
+// cache_ttl is served from the listing cache, and that a third List
+// after the TTL expires goes through to the wrapped Fs again.
+func TestCacheServesSecondListFromCache(t *testing.T) {
+	localDir, err := ioutil.TempDir("", "rclone-cache-backend-test")
+	require.NoError(t, err)
+	cacheDir, err := ioutil.TempDir("", "rclone-cache-dir-test")
+	require.NoError(t, err)
+
+	fs.ConfigFileSet("TestCacheList", "type", "cache")
+	fs.ConfigFileSet("TestCacheList", "remote", localDir)
+	fs.ConfigFileSet("TestCacheList", "cache_dir", cacheDir)
+	fs.ConfigFileSet("TestCacheList", "cache_ttl", "50ms")
+
+	f, err := fs.NewFs("TestCacheList:")
+	require.NoError(t, err)
+	cf := f.(*cache.Fs)
+
+	require.NoError(t, f.Mkdir(""))
+	info := fs.NewStaticObjectInfo("file.txt", time.Now(), 4, true, nil, nil)
+	_, err = f.Put(strings.NewReader("data"), info)
+	require.NoError(t, err)
+
+	_, err = f.List("")
+	require.NoError(t, err)
+	_, err = f.List("")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, cf.BackendLists())
+
+	time.Sleep(100 * time.Millisecond)
+	_, err = f.List("")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, cf.BackendLists())
+}
+
+// TestCacheInvalidatesListingOnWrite checks that Put invalidates the
+// cached listing of the directory the new object landed in, so a List
+// immediately after a write sees it rather than serving a stale listing
+// cached before the write (cache_ttl is set long enough that only
+// invalidation, not TTL expiry, can explain the second List seeing it).
+func TestCacheInvalidatesListingOnWrite(t *testing.T) {
+	localDir, err := ioutil.TempDir("", "rclone-cache-backend-test")
+	require.NoError(t, err)
+	cacheDir, err := ioutil.TempDir("", "rclone-cache-dir-test")
+	require.NoError(t, err)
+
+	fs.ConfigFileSet("TestCacheInvalidate", "type", "cache")
+	fs.ConfigFileSet("TestCacheInvalidate", "remote", localDir)
+	fs.ConfigFileSet("TestCacheInvalidate", "cache_dir", cacheDir)
+	fs.ConfigFileSet("TestCacheInvalidate", "cache_ttl", "1h")
+
+	f, err := fs.NewFs("TestCacheInvalidate:")
+	require.NoError(t, err)
+
+	require.NoError(t, f.Mkdir(""))
+	entries, err := f.List("")
+	require.NoError(t, err)
+	require.Len(t, entries, 0)
+
+	info := fs.NewStaticObjectInfo("file.txt", time.Now(), 4, true, nil, nil)
+	_, err = f.Put(strings.NewReader("data"), info)
+	require.NoError(t, err)
+
+	entries, err = f.List("")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+// TestCacheEvictsOldestWhenOverSize checks that once the cached data
+// exceeds cache_max_size, the oldest cached file is evicted.
+func TestCacheEvictsOldestWhenOverSize(t *testing.T) {
+	localDir, err := ioutil.TempDir("", "rclone-cache-backend-test")
+	require.NoError(t, err)
+	cacheDir, err := ioutil.TempDir("", "rclone-cache-dir-test")
+	require.NoError(t, err)
+
+	fs.ConfigFileSet("TestCacheEvict", "type", "cache")
+	fs.ConfigFileSet("TestCacheEvict", "remote", localDir)
+	fs.ConfigFileSet("TestCacheEvict", "cache_dir", cacheDir)
+	fs.ConfigFileSet("TestCacheEvict", "cache_max_size", "10")
+
+	f, err := fs.NewFs("TestCacheEvict:")
+	require.NoError(t, err)
+
+	require.NoError(t, f.Mkdir(""))
+	for _, name := range []string{"a.txt", "b.txt"} {
+		content := "0123456789"
+		info := fs.NewStaticObjectInfo(name, time.Now(), int64(len(content)), true, nil, nil)
+		_, err = f.Put(strings.NewReader(content), info)
+		require.NoError(t, err)
+		o, err := f.NewObject(name)
+		require.NoError(t, err)
+		rc, err := o.Open()
+		require.NoError(t, err)
+		_, err = ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	entries, err := ioutil.ReadDir(cacheDir + "/data")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
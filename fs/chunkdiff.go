@@ -0,0 +1,196 @@
+package fs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+// ChunkAction describes what should happen to a chunk when turning a
+// ChunkDiff into a delta transfer
+type ChunkAction int
+
+// Chunk actions
+const (
+	ChunkKeep    ChunkAction = iota // chunk is unchanged, no need to transfer it
+	ChunkReplace                    // chunk at this offset changed, replace it
+	ChunkInsert                     // a new chunk was inserted with no counterpart in dst
+)
+
+// ChunkOp is one content-defined chunk found in src, together with the
+// action a delta-aware backend should take to reconcile it against dst
+type ChunkOp struct {
+	Offset int64
+	Len    int64
+	Action ChunkAction
+	Hash   string // strong hash (SHA-256) of the chunk contents
+}
+
+// Rolling hash / chunking parameters. These must be identical on both
+// sides of a comparison so chunk boundaries line up.
+const (
+	cdcMinChunk = 512 * 1024
+	cdcMaxChunk = 8 * 1024 * 1024
+	cdcAvgBits  = 20 // average chunk size of 2^20 = 1MiB
+	cdcWindow   = 64 // rolling window size in bytes
+	cdcMask     = 1<<cdcAvgBits - 1
+)
+
+// buzhashTable is a fixed, deterministic 256-entry table so both sides of
+// a ChunkDiff produce identical chunk boundaries without sharing state
+var buzhashTable = func() [256]uint64 {
+	var t [256]uint64
+	// A fixed xorshift64 PRNG seed - deterministic, not security
+	// sensitive, only needs to scatter bits evenly.
+	seed := uint64(0x9e3779b97f4a7c15)
+	for i := range t {
+		seed ^= seed << 13
+		seed ^= seed >> 7
+		seed ^= seed << 17
+		t[i] = seed
+	}
+	return t
+}()
+
+// cdcChunker finds content-defined chunk boundaries in a stream, emitting
+// chunks bounded by [cdcMinChunk, cdcMaxChunk] whenever the rolling hash
+// hits cdcMask, so that an insertion/deletion only perturbs the chunks
+// immediately around it instead of every chunk downstream.
+type cdcChunker struct {
+	r   io.Reader
+	buf bytes.Buffer
+	off int64
+	eof bool
+}
+
+func newCDCChunker(r io.Reader) *cdcChunker {
+	return &cdcChunker{r: r}
+}
+
+// Next returns the next chunk's bytes and starting offset, or io.EOF once
+// the stream is exhausted
+func (c *cdcChunker) Next() (data []byte, offset int64, err error) {
+	if c.eof && c.buf.Len() == 0 {
+		return nil, 0, io.EOF
+	}
+
+	window := make([]byte, 0, cdcWindow)
+	var h uint64
+	chunk := make([]byte, 0, cdcMinChunk)
+	one := make([]byte, 1)
+
+	for {
+		if len(chunk) >= cdcMinChunk {
+			if len(window) == cdcWindow {
+				h = h<<1 ^ buzhashTable[window[0]] ^ buzhashTable[one[0]]
+			}
+			if len(chunk) >= cdcMinChunk && h&cdcMask == cdcMask {
+				break
+			}
+			if len(chunk) >= cdcMaxChunk {
+				break
+			}
+		}
+
+		n, rerr := c.readByte(one)
+		if n == 0 {
+			if rerr != nil && rerr != io.EOF {
+				return nil, 0, rerr
+			}
+			c.eof = true
+			break
+		}
+		chunk = append(chunk, one[0])
+		window = append(window, one[0])
+		if len(window) > cdcWindow {
+			window = window[1:]
+		}
+		h = h<<1 ^ buzhashTable[one[0]]
+		if rerr != nil && rerr != io.EOF {
+			return nil, 0, rerr
+		}
+		if rerr == io.EOF {
+			c.eof = true
+		}
+	}
+
+	if len(chunk) == 0 {
+		return nil, 0, io.EOF
+	}
+	start := c.off
+	c.off += int64(len(chunk))
+	return chunk, start, nil
+}
+
+func (c *cdcChunker) readByte(p []byte) (int, error) {
+	return c.r.Read(p[:1])
+}
+
+// hashChunk computes the strong hash used to match chunks across streams
+func hashChunk(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// ChunkDiff splits dst and src using content-defined chunking and returns
+// the operations needed to turn dst into src by transferring only the
+// chunks that changed - useful for backends that support range PUTs.
+func ChunkDiff(dst, src io.Reader) ([]ChunkOp, error) {
+	dstChunks, dstLen, err := chunksOf(dst)
+	if err != nil {
+		return nil, err
+	}
+	srcChunker := newCDCChunker(src)
+
+	dstByHash := map[string]bool{}
+	for _, h := range dstChunks {
+		dstByHash[h] = true
+	}
+
+	var ops []ChunkOp
+	for {
+		data, offset, err := srcChunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		sum := hashChunk(data)
+		action := ChunkInsert
+		if dstByHash[sum] {
+			action = ChunkKeep
+		} else if offset < dstLen {
+			action = ChunkReplace
+		}
+		ops = append(ops, ChunkOp{
+			Offset: offset,
+			Len:    int64(len(data)),
+			Action: action,
+			Hash:   sum,
+		})
+	}
+	return ops, nil
+}
+
+// chunksOf splits r into content-defined chunks and returns their strong
+// hashes, used to build the lookup table ChunkDiff matches src against,
+// together with the total length of r
+func chunksOf(r io.Reader) ([]string, int64, error) {
+	chunker := newCDCChunker(r)
+	var hashes []string
+	var total int64
+	for {
+		data, _, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		hashes = append(hashes, hashChunk(data))
+		total += int64(len(data))
+	}
+	return hashes, total, nil
+}
@@ -0,0 +1,40 @@
+package fs_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkDiffIdentical(t *testing.T) {
+	b := bytes.Repeat([]byte("abcdefgh"), 256*1024) // 2MiB, well above cdcMinChunk
+
+	ops, err := fs.ChunkDiff(bytes.NewReader(b), bytes.NewReader(b))
+	require.NoError(t, err)
+	require.NotEmpty(t, ops)
+	for _, op := range ops {
+		assert.Equal(t, fs.ChunkKeep, op.Action)
+	}
+}
+
+func TestChunkDiffLengthMismatch(t *testing.T) {
+	dst := bytes.Repeat([]byte("x"), 65*1024)
+	src := bytes.Repeat([]byte("x"), 66*1024)
+
+	ops, err := fs.ChunkDiff(bytes.NewReader(dst), bytes.NewReader(src))
+	require.NoError(t, err)
+	assert.NotEmpty(t, ops)
+}
+
+func TestChunkDiffPropagatesReaderErrors(t *testing.T) {
+	boom := assertErrReader{err: assert.AnError}
+	_, err := fs.ChunkDiff(bytes.NewReader(nil), boom)
+	assert.Equal(t, assert.AnError, err)
+}
+
+type assertErrReader struct{ err error }
+
+func (er assertErrReader) Read(p []byte) (int, error) { return 0, er.err }
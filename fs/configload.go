@@ -0,0 +1,150 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// LoadConfigFile reads a YAML or JSON file of the form
+//
+//	myremote:
+//	  type: s3
+//	  access_key_id: AKIA...
+//
+// and materializes every key into the same in-memory config store
+// ConfigFileGetStruct reads from and EditConfig writes to. JSON is
+// valid YAML, so one decoder handles both formats.
+//
+// Keys the backend declares as IsPassword (see fs.Option) are obscured
+// with MustObscure on the way in, so the store stays consistent with the
+// "always obscured" convention of the on-disk INI.
+func LoadConfigFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config: failed to open %q: %w", path, err)
+	}
+	defer f.Close()
+
+	var raw map[string]map[string]interface{}
+	if err := yaml.NewDecoder(f).Decode(&raw); err != nil {
+		return fmt.Errorf("config: failed to parse %q: %w", path, err)
+	}
+
+	for remote, settings := range raw {
+		// set "type" first so obscureIfSecret can look up the backend's
+		// declared options for every other key in this remote, regardless
+		// of the map's iteration order
+		if typ, ok := settings["type"]; ok {
+			ConfigFileSet(remote, "type", fmt.Sprintf("%v", typ))
+		}
+		for key, value := range settings {
+			if key == "type" {
+				continue
+			}
+			ConfigFileSet(remote, key, obscureIfSecret(remote, key, fmt.Sprintf("%v", value)))
+		}
+	}
+	return nil
+}
+
+// OverlayConfigEnv overlays environment variables of the form
+// RCLONE_<REMOTE>_<KEY> (remote and key uppercased, dashes turned to
+// underscores) onto every remote already known to the config store -
+// typically one LoadConfigFile or the interactive editor populated -
+// taking precedence over whatever they set. Call this after
+// LoadConfigFile so env wins the merge.
+func OverlayConfigEnv() {
+	for _, remote := range ConfigFileSections() {
+		prefix := "RCLONE_" + envName(remote) + "_"
+		for _, kv := range os.Environ() {
+			key, value, ok := splitEnv(kv)
+			if !ok || !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			settingKey := strings.ToLower(strings.TrimPrefix(key, prefix))
+			ConfigFileSet(remote, settingKey, obscureIfSecret(remote, settingKey, value))
+		}
+	}
+}
+
+// EffectiveConfig returns every configured remote's settings as plain
+// strings, for "config show" - the merged result of the INI file, any
+// --config-file, and any RCLONE_<REMOTE>_<KEY> overlay.
+func EffectiveConfig() map[string]map[string]string {
+	out := make(map[string]map[string]string)
+	for _, remote := range ConfigFileSections() {
+		settings := make(map[string]string)
+		for _, key := range ConfigFileKeys(remote) {
+			if value, ok := ConfigFileGet(remote, key); ok {
+				settings[key] = value
+			}
+		}
+		out[remote] = settings
+	}
+	return out
+}
+
+// CheckConfig validates that every configured remote can actually be
+// constructed from its settings, surfacing the same errors NewFs would
+// hit at first use - e.g. a missing required option or unknown type.
+func CheckConfig() error {
+	var problems []string
+	for _, remote := range ConfigFileSections() {
+		typ, ok := ConfigFileGet(remote, "type")
+		if !ok || typ == "" {
+			problems = append(problems, fmt.Sprintf("%s: missing \"type\"", remote))
+			continue
+		}
+		if _, err := Find(typ); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: unknown type %q", remote, typ))
+			continue
+		}
+		if _, err := NewFs(remote + ":"); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", remote, err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("config check found %d problem(s):\n%s", len(problems), strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// obscureIfSecret obscures value if remote's backend type declares key as
+// an IsPassword option (see e.g. smb/smb.go's "pass" option), matching
+// the same notion of "secret" the interactive config editor and the INI
+// file use. A remote with no known type, or a key the backend doesn't
+// declare at all, is left as-is rather than guessed at.
+func obscureIfSecret(remote, key, value string) string {
+	typ, ok := ConfigFileGet(remote, "type")
+	if !ok {
+		return value
+	}
+	regInfo, err := Find(typ)
+	if err != nil {
+		return value
+	}
+	for _, opt := range regInfo.Options {
+		if opt.Name == key {
+			if opt.IsPassword {
+				return MustObscure(value)
+			}
+			return value
+		}
+	}
+	return value
+}
+
+func envName(remote string) string {
+	return strings.ToUpper(strings.Replace(remote, "-", "_", -1))
+}
+
+func splitEnv(kv string) (key, value string, ok bool) {
+	i := strings.IndexByte(kv, '=')
+	if i < 0 {
+		return "", "", false
+	}
+	return kv[:i], kv[i+1:], true
+}
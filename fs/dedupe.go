@@ -0,0 +1,255 @@
+package fs
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DeduplicateMode is how duplicates should be removed
+type DeduplicateMode int
+
+// Deduplicate modes
+const (
+	DeduplicateInteractive DeduplicateMode = iota // interactively ask the user which to keep
+	DeduplicateSkip                               // skip all conflicts
+	DeduplicateFirst                              // keep the first file found
+	DeduplicateNewest                             // keep the newest file found
+	DeduplicateOldest                             // keep the oldest file found
+	DeduplicateRename                             // rename the conflicting files
+	DeduplicateLargest                            // keep the largest file found
+	DeduplicateSmallest                           // keep the smallest file found
+	DeduplicateByHash                             // group across the whole remote by content hash, not just by name
+)
+
+// dupeGroup is one set of objects which need deduplicating, either
+// because they share a Remote() or (for DeduplicateByHash) a hash
+type dupeGroup struct {
+	remote  string
+	objects []Object
+}
+
+// Deduplicate removes duplicate files, either interactively, according to
+// a fixed rule, or across the whole remote by content hash
+func Deduplicate(f Fs, mode DeduplicateMode) error {
+	if mode == DeduplicateByHash {
+		return deduplicateByHash(f)
+	}
+
+	groups, err := groupByName(f)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if len(group.objects) <= 1 {
+			continue
+		}
+		if err := dedupeGroup(f, group, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupByName buckets every object in f by its Remote() path
+func groupByName(f Fs) ([]*dupeGroup, error) {
+	byName := map[string]*dupeGroup{}
+	var order []string
+
+	list := NewLister().Start(f, "")
+	for {
+		o, err := list.GetObject()
+		if err != nil {
+			return nil, fmt.Errorf("dedupe: failed to list %v: %w", f, err)
+		}
+		if o == nil {
+			break
+		}
+		g, ok := byName[o.Remote()]
+		if !ok {
+			g = &dupeGroup{remote: o.Remote()}
+			byName[o.Remote()] = g
+			order = append(order, o.Remote())
+		}
+		g.objects = append(g.objects, o)
+	}
+
+	groups := make([]*dupeGroup, len(order))
+	for i, remote := range order {
+		groups[i] = byName[remote]
+	}
+	return groups, nil
+}
+
+// dedupeGroup resolves a single group of same-named duplicates according
+// to mode, after first removing any that are identical by hash to one
+// already seen in the group
+func dedupeGroup(f Fs, group *dupeGroup, mode DeduplicateMode) error {
+	if ht := f.Hashes().GetOne(); ht != HashNone {
+		seen := map[string]bool{}
+		var unique []Object
+		for _, o := range group.objects {
+			sum, err := o.Hash(ht)
+			if err != nil || sum == "" || !seen[sum] {
+				seen[sum] = true
+				unique = append(unique, o)
+			} else if err := o.Remove(); err != nil {
+				return fmt.Errorf("dedupe: failed to remove identical duplicate %v: %w", o, err)
+			}
+		}
+		if group = (&dupeGroup{remote: group.remote, objects: unique}); len(unique) <= 1 {
+			return nil
+		}
+	}
+	keep, rest := pickWinner(group.objects, mode)
+	switch mode {
+	case DeduplicateSkip:
+		log.Printf("%q: %d duplicates found, skipping", group.remote, len(group.objects))
+		return nil
+	case DeduplicateInteractive:
+		keep, rest = askWinner(group)
+		_ = keep
+		for _, o := range rest {
+			if err := o.Remove(); err != nil {
+				return fmt.Errorf("dedupe: failed to remove duplicate %v: %w", o, err)
+			}
+		}
+		return nil
+	case DeduplicateRename:
+		return renameDupes(f, group.objects)
+	default:
+		_ = keep
+		for _, o := range rest {
+			if err := o.Remove(); err != nil {
+				return fmt.Errorf("dedupe: failed to remove duplicate %v: %w", o, err)
+			}
+		}
+		return nil
+	}
+}
+
+// pickWinner returns the object to keep and the rest to remove, according
+// to mode. Objects are assumed to already share a Remote().
+func pickWinner(objects []Object, mode DeduplicateMode) (keep Object, rest []Object) {
+	best := 0
+	for i := 1; i < len(objects); i++ {
+		if better(objects[i], objects[best], mode) {
+			best = i
+		}
+	}
+	keep = objects[best]
+	for i, o := range objects {
+		if i != best {
+			rest = append(rest, o)
+		}
+	}
+	return keep, rest
+}
+
+// askWinner prompts the user to pick which object in group to keep,
+// defaulting to the first if the answer is empty or unrecognised
+func askWinner(group *dupeGroup) (keep Object, rest []Object) {
+	fmt.Fprintf(os.Stderr, "%q: %d duplicates found\n", group.remote, len(group.objects))
+	for i, o := range group.objects {
+		fmt.Fprintf(os.Stderr, "  %d: %v, size %d, modified %v\n", i+1, o, o.Size(), o.ModTime())
+	}
+	fmt.Fprintf(os.Stderr, "Which one to keep (1-%d, default 1)? ", len(group.objects))
+
+	best := 0
+	scanner := bufio.NewScanner(os.Stdin)
+	if scanner.Scan() {
+		if n, err := strconv.Atoi(strings.TrimSpace(scanner.Text())); err == nil && n >= 1 && n <= len(group.objects) {
+			best = n - 1
+		}
+	}
+
+	keep = group.objects[best]
+	for i, o := range group.objects {
+		if i != best {
+			rest = append(rest, o)
+		}
+	}
+	return keep, rest
+}
+
+// better reports whether a should be preferred over b under mode
+func better(a, b Object, mode DeduplicateMode) bool {
+	switch mode {
+	case DeduplicateNewest:
+		return a.ModTime().After(b.ModTime())
+	case DeduplicateOldest:
+		return a.ModTime().Before(b.ModTime())
+	case DeduplicateLargest:
+		return a.Size() > b.Size()
+	case DeduplicateSmallest:
+		return a.Size() < b.Size()
+	case DeduplicateFirst:
+		fallthrough
+	default:
+		return false // keep whichever was seen first
+	}
+}
+
+// renameDupes renames every object in the group to remote-N.ext so none
+// of them are lost
+func renameDupes(f Fs, objects []Object) error {
+	mover, ok := f.(Mover)
+	if !ok {
+		return fmt.Errorf("dedupe: %v does not support server-side move", f)
+	}
+	for i, o := range objects {
+		ext := path.Ext(o.Remote())
+		base := o.Remote()[:len(o.Remote())-len(ext)]
+		newRemote := fmt.Sprintf("%s-%d%s", base, i+1, ext)
+		if _, err := mover.Move(o, newRemote); err != nil {
+			return fmt.Errorf("dedupe: failed to rename %v to %q: %w", o, newRemote, err)
+		}
+	}
+	return nil
+}
+
+// deduplicateByHash groups every object in f by its common hash type,
+// regardless of path, and removes every duplicate but the
+// lexicographically first Remote() in each bucket. This catches
+// cross-directory duplicates that groupByName misses.
+func deduplicateByHash(f Fs) error {
+	ht := f.Hashes().GetOne()
+	if ht == HashNone {
+		return fmt.Errorf("dedupe: %v has no usable hash type for DeduplicateByHash", f)
+	}
+
+	byHash := map[string][]Object{}
+	list := NewLister().Start(f, "")
+	for {
+		o, err := list.GetObject()
+		if err != nil {
+			return fmt.Errorf("dedupe: failed to list %v: %w", f, err)
+		}
+		if o == nil {
+			break
+		}
+		sum, err := o.Hash(ht)
+		if err != nil || sum == "" {
+			continue
+		}
+		byHash[sum] = append(byHash[sum], o)
+	}
+
+	for _, objects := range byHash {
+		if len(objects) <= 1 {
+			continue
+		}
+		sort.Slice(objects, func(i, j int) bool { return objects[i].Remote() < objects[j].Remote() })
+		for _, o := range objects[1:] {
+			if err := o.Remove(); err != nil {
+				return fmt.Errorf("dedupe: failed to remove hash duplicate %v: %w", o, err)
+			}
+		}
+	}
+	return nil
+}
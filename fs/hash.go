@@ -0,0 +1,102 @@
+package fs
+
+import "errors"
+
+// HashType indicates a supported hash type of a remote
+type HashType int
+
+// Hash types supported by rclone backends
+const (
+	HashNone HashType = 0
+	HashMD5  HashType = 1 << (iota - 1)
+	HashSHA1
+	HashSHA256
+	HashSHA512
+	HashBLAKE2b256
+)
+
+// ErrHashUnsupported should be returned by Object.Hash if the
+// requested HashType isn't supported by that object
+var ErrHashUnsupported = errors.New("hash type not supported")
+
+// HashSet is a set of HashTypes, stored as a bitmask
+type HashSet int
+
+// NewHashSet returns a HashSet containing the given HashTypes
+func NewHashSet(types ...HashType) HashSet {
+	var h HashSet
+	for _, t := range types {
+		h |= HashSet(t)
+	}
+	return h
+}
+
+// Contains returns whether h includes t
+func (h HashSet) Contains(t HashType) bool { return h&HashSet(t) != 0 }
+
+// Overlap returns the HashTypes present in both h and other, for finding
+// a hash algorithm two Fs can agree on when comparing objects across them
+func (h HashSet) Overlap(other HashSet) HashSet { return h & other }
+
+// GetOne returns a single HashType out of h, preferring MD5 and falling
+// back to whatever else is supported, or HashNone if h is empty. Used
+// where any one mutually-understood hash will do, such as grouping
+// objects by content.
+func (h HashSet) GetOne() HashType {
+	if h.Contains(HashMD5) {
+		return HashMD5
+	}
+	for _, t := range []HashType{HashSHA1, HashSHA256, HashSHA512, HashBLAKE2b256} {
+		if h.Contains(t) {
+			return t
+		}
+	}
+	return HashNone
+}
+
+// hashWidth is the hex-encoded width of each hash type's digest, used
+// to right-align hashsum output the same way the system md5sum/sha1sum
+// tools do
+var hashWidth = map[HashType]int{
+	HashMD5:        32,
+	HashSHA1:       40,
+	HashSHA256:     64,
+	HashSHA512:     128,
+	HashBLAKE2b256: 64,
+}
+
+// String returns the canonical lower-case name of the hash type, as
+// used on the command line (e.g. "hashsum sha256")
+func (h HashType) String() string {
+	switch h {
+	case HashMD5:
+		return "md5"
+	case HashSHA1:
+		return "sha1"
+	case HashSHA256:
+		return "sha256"
+	case HashSHA512:
+		return "sha512"
+	case HashBLAKE2b256:
+		return "blake2b-256"
+	default:
+		return "none"
+	}
+}
+
+// ParseHashType turns a command-line algorithm name into a HashType
+func ParseHashType(name string) (HashType, error) {
+	switch name {
+	case "md5":
+		return HashMD5, nil
+	case "sha1":
+		return HashSHA1, nil
+	case "sha256":
+		return HashSHA256, nil
+	case "sha512":
+		return HashSHA512, nil
+	case "blake2b-256":
+		return HashBLAKE2b256, nil
+	}
+	return HashNone, errors.New("unknown hash type " + name)
+}
@@ -0,0 +1,90 @@
+package fs
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// HashSum writes a hash file for every object in fsrc to w, in the
+// "<hex>  <path>" format used by the system md5sum/sha1sum tools.
+// It prefers each object's native hash where the backend supports ht,
+// falling back to streaming the object through the algorithm itself.
+func HashSum(fsrc Fs, ht HashType, w io.Writer) error {
+	width := hashWidth[ht]
+	list := NewLister().Start(fsrc, "")
+	for {
+		o, err := list.GetObject()
+		if err != nil {
+			return fmt.Errorf("hashsum: failed to list %v: %w", fsrc, err)
+		}
+		if o == nil {
+			break
+		}
+
+		sum, err := o.Hash(ht)
+		if err == ErrHashUnsupported {
+			sum, err = streamHash(o, ht)
+		}
+		if err != nil {
+			log.Printf("%v: failed to read %v hash: %v", o, ht, err)
+			sum = strings.Repeat("-", width)
+		}
+		fmt.Fprintf(w, "%*s  %s\n", width, sum, o.Remote())
+	}
+	return nil
+}
+
+// streamHash computes ht by opening o and reading it through the
+// algorithm, for backends that don't expose a native hash
+func streamHash(o Object, ht HashType) (string, error) {
+	h, err := newHasher(ht)
+	if err != nil {
+		return "", err
+	}
+	rc, err := o.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	if _, err := io.Copy(h, rc); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func newHasher(ht HashType) (hash.Hash, error) {
+	switch ht {
+	case HashMD5:
+		return md5.New(), nil
+	case HashSHA1:
+		return sha1.New(), nil
+	case HashSHA256:
+		return sha256.New(), nil
+	case HashSHA512:
+		return sha512.New(), nil
+	case HashBLAKE2b256:
+		return blake2b.New256(nil)
+	}
+	return nil, fmt.Errorf("hashsum: unsupported hash type %v", ht)
+}
+
+// Md5sum is a thin alias for HashSum(fsrc, HashMD5, w), kept for
+// backward compatibility with the original single-purpose md5sum command
+func Md5sum(fsrc Fs, w io.Writer) error {
+	return HashSum(fsrc, HashMD5, w)
+}
+
+// Sha1sum is a thin alias for HashSum(fsrc, HashSHA1, w)
+func Sha1sum(fsrc Fs, w io.Writer) error {
+	return HashSum(fsrc, HashSHA1, w)
+}
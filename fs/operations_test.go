@@ -675,6 +675,57 @@ func TestDeduplicateRename(t *testing.T) {
 	}
 }
 
+func TestDeduplicateLargest(t *testing.T) {
+	r := NewRun(t)
+	defer r.Finalise()
+	skipIfCantDedupe(t, r.fremote)
+
+	file1 := r.WriteUncheckedObject("one", "This is one", t1)
+	file2 := r.WriteUncheckedObject("one", "This is one too", t1)
+	file3 := r.WriteUncheckedObject("one", "This is another, much longer one", t1)
+	r.checkWithDuplicates(t, file1, file2, file3)
+
+	err := fs.Deduplicate(r.fremote, fs.DeduplicateLargest)
+	require.NoError(t, err)
+
+	fstest.CheckItems(t, r.fremote, file3)
+}
+
+func TestDeduplicateSmallest(t *testing.T) {
+	r := NewRun(t)
+	defer r.Finalise()
+	skipIfCantDedupe(t, r.fremote)
+
+	file1 := r.WriteUncheckedObject("one", "This is one", t1)
+	file2 := r.WriteUncheckedObject("one", "This is one too", t1)
+	file3 := r.WriteUncheckedObject("one", "This is another, much longer one", t1)
+	r.checkWithDuplicates(t, file1, file2, file3)
+
+	err := fs.Deduplicate(r.fremote, fs.DeduplicateSmallest)
+	require.NoError(t, err)
+
+	fstest.CheckItems(t, r.fremote, file1)
+}
+
+func TestDeduplicateByHash(t *testing.T) {
+	r := NewRun(t)
+	defer r.Finalise()
+	skipIfCantDedupe(t, r.fremote)
+
+	// "one" and "sub/two" share content, so they collide only once the
+	// whole remote is grouped by hash, not by name
+	file1 := r.WriteObject("one", "duplicated content", t1)
+	file2 := r.WriteObject("sub/two", "duplicated content", t1)
+	file3 := r.WriteObject("three", "unique content", t1)
+	fstest.CheckItems(t, r.fremote, file1, file2, file3)
+
+	err := fs.Deduplicate(r.fremote, fs.DeduplicateByHash)
+	require.NoError(t, err)
+
+	// "one" sorts before "sub/two" so it is the one kept
+	fstest.CheckItems(t, r.fremote, file1, file3)
+}
+
 func TestCat(t *testing.T) {
 	r := NewRun(t)
 	defer r.Finalise()
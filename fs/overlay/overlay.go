@@ -0,0 +1,307 @@
+// Package overlay stacks a writable Fs ("upper") over a read-only Fs
+// ("base"), similar to afero's copyOnWriteFs or a union mount.
+package overlay
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+)
+
+// whiteoutPrefix marks a zero-byte object in the upper layer that records
+// the deletion of a same-named object in the base layer.
+const whiteoutPrefix = ".wh."
+
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name:        "overlay",
+		Description: "Overlay a writable remote over a read-only base remote",
+		NewFs:       NewFs,
+		Options: []fs.Option{{
+			Name: "base",
+			Help: "Remote to use as the read-only base layer, e.g. \"remote:path\".",
+		}, {
+			Name: "upper",
+			Help: "Remote to use as the writable top layer, e.g. \"remote:path\".",
+		}},
+	})
+}
+
+// Options for this backend
+type Options struct {
+	Base  string `config:"base"`
+	Upper string `config:"upper"`
+}
+
+// Fs merges a read-only base Fs and a writable upper Fs
+type Fs struct {
+	name     string
+	root     string
+	base     fs.Fs
+	upper    fs.Fs
+	features *fs.Features
+}
+
+// Object is either a base or upper-layer object, exposed through the
+// merged overlay namespace
+type Object struct {
+	f      *Fs
+	o      fs.Object
+	onBase bool
+}
+
+// NewFs constructs an overlay Fs from name and root path (unused - the
+// base and upper remotes are supplied as config)
+func NewFs(name, root string) (fs.Fs, error) {
+	opt := new(Options)
+	if err := fs.ConfigFileGetStruct(name, opt); err != nil {
+		return nil, err
+	}
+	base, err := fs.NewFs(opt.Base)
+	if err != nil {
+		return nil, fmt.Errorf("overlay: failed to make base remote %q: %w", opt.Base, err)
+	}
+	upper, err := fs.NewFs(opt.Upper)
+	if err != nil {
+		return nil, fmt.Errorf("overlay: failed to make upper remote %q: %w", opt.Upper, err)
+	}
+
+	f := &Fs{
+		name:  name,
+		root:  root,
+		base:  base,
+		upper: upper,
+	}
+	f.features = (&fs.Features{
+		CanHaveEmptyDirectories: true,
+	}).Fill(f)
+	return f, nil
+}
+
+// Name of the remote
+func (f *Fs) Name() string { return f.name }
+
+// Root of the remote
+func (f *Fs) Root() string { return f.root }
+
+// String converts this Fs to a string
+func (f *Fs) String() string {
+	return fmt.Sprintf("overlay base %s upper %s", f.base.String(), f.upper.String())
+}
+
+// Precision of the least precise layer
+func (f *Fs) Precision() time.Duration {
+	if bp, up := f.base.Precision(), f.upper.Precision(); bp > up {
+		return bp
+	} else {
+		return up
+	}
+}
+
+// Hashes is the intersection of what both layers support, since a
+// comparison must be possible regardless of which layer serves an object
+func (f *Fs) Hashes() fs.HashSet { return f.base.Hashes().Overlap(f.upper.Hashes()) }
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features { return f.features }
+
+func whiteoutName(leaf string) string { return whiteoutPrefix + leaf }
+
+func isWhiteout(leaf string) (string, bool) {
+	if strings.HasPrefix(leaf, whiteoutPrefix) {
+		return strings.TrimPrefix(leaf, whiteoutPrefix), true
+	}
+	return "", false
+}
+
+// List merges entries from upper and base, with upper shadowing base and
+// whiteouts in upper hiding the matching base entry
+func (f *Fs) List(dir string) (fs.DirEntries, error) {
+	upperEntries, upperErr := f.upper.List(dir)
+	if upperErr != nil && upperErr != fs.ErrorDirNotFound {
+		return nil, upperErr
+	}
+	baseEntries, baseErr := f.base.List(dir)
+	if baseErr != nil && baseErr != fs.ErrorDirNotFound {
+		return nil, baseErr
+	}
+	if upperErr == fs.ErrorDirNotFound && baseErr == fs.ErrorDirNotFound {
+		return nil, fs.ErrorDirNotFound
+	}
+
+	seen := map[string]bool{}
+	whiteouts := map[string]bool{}
+	var merged fs.DirEntries
+
+	for _, e := range upperEntries {
+		leaf := path.Base(e.Remote())
+		if target, ok := isWhiteout(leaf); ok {
+			whiteouts[target] = true
+			continue
+		}
+		seen[leaf] = true
+		merged = append(merged, f.wrapEntry(e, false))
+	}
+	for _, e := range baseEntries {
+		leaf := path.Base(e.Remote())
+		if seen[leaf] || whiteouts[leaf] {
+			continue
+		}
+		merged = append(merged, f.wrapEntry(e, true))
+	}
+	return merged, nil
+}
+
+// wrapEntry wraps a Directory as-is and an Object in our Object type so
+// reads can be pulled from whichever layer holds it
+func (f *Fs) wrapEntry(e fs.DirEntry, onBase bool) fs.DirEntry {
+	if o, ok := e.(fs.Object); ok {
+		return &Object{f: f, o: o, onBase: onBase}
+	}
+	return e
+}
+
+// NewObject finds the Object at remote, preferring the upper layer
+func (f *Fs) NewObject(remote string) (fs.Object, error) {
+	if whiteoutExists(f.upper, remote) {
+		return nil, fs.ErrorObjectNotFound
+	}
+	if o, err := f.upper.NewObject(remote); err == nil {
+		return &Object{f: f, o: o, onBase: false}, nil
+	}
+	o, err := f.base.NewObject(remote)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{f: f, o: o, onBase: true}, nil
+}
+
+func whiteoutExists(upper fs.Fs, remote string) bool {
+	_, err := upper.NewObject(path.Join(path.Dir(remote), whiteoutName(path.Base(remote))))
+	return err == nil
+}
+
+// Put always writes to the upper layer
+func (f *Fs) Put(in io.Reader, src fs.ObjectInfo) (fs.Object, error) {
+	// clear a whiteout first, a new Put resurrects the path
+	_ = f.upper.Mkdir(path.Dir(src.Remote()))
+	if wh, err := f.upper.NewObject(path.Join(path.Dir(src.Remote()), whiteoutName(path.Base(src.Remote())))); err == nil {
+		_ = wh.Remove()
+	}
+	o, err := f.upper.Put(in, src)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{f: f, o: o, onBase: false}, nil
+}
+
+// Mkdir creates dir in the upper layer
+func (f *Fs) Mkdir(dir string) error { return f.upper.Mkdir(dir) }
+
+// Rmdir removes dir from the upper layer only - base layer directories
+// are read-only and are simply no longer listed once empty of upper
+// entries
+func (f *Fs) Rmdir(dir string) error { return f.upper.Rmdir(dir) }
+
+// Remove deletes an object. If it only exists in base, a whiteout is
+// recorded in upper instead of a real delete. If it lives in upper, it is
+// removed directly, but a whiteout is also written whenever a same-named
+// base copy would otherwise resurface through List/NewObject once the
+// upper copy is gone.
+func (o *Object) Remove() error {
+	if !o.onBase {
+		if err := o.o.Remove(); err != nil {
+			return err
+		}
+		if _, err := o.f.base.NewObject(o.Remote()); err != nil {
+			return nil
+		}
+		return o.writeWhiteout()
+	}
+	return o.writeWhiteout()
+}
+
+// writeWhiteout records a whiteout for o.Remote() in the upper layer, so
+// List/NewObject stop seeing any base-layer copy of it
+func (o *Object) writeWhiteout() error {
+	wh := fs.NewStaticObjectInfo(
+		path.Join(path.Dir(o.Remote()), whiteoutName(path.Base(o.Remote()))),
+		time.Now(), 0, true, nil, o.f.upper,
+	)
+	_, err := o.f.upper.Put(strings.NewReader(""), wh)
+	return err
+}
+
+// Fs returns the parent Fs
+func (o *Object) Fs() fs.Info { return o.f }
+
+// Remote returns the remote path of the object
+func (o *Object) Remote() string { return o.o.Remote() }
+
+// String returns a description of the Object
+func (o *Object) String() string { return o.o.String() }
+
+// Size returns the size of the underlying object
+func (o *Object) Size() int64 { return o.o.Size() }
+
+// ModTime returns the modification time of the underlying object
+func (o *Object) ModTime() time.Time { return o.o.ModTime() }
+
+// Hash returns the hash of the underlying object
+func (o *Object) Hash(ht fs.HashType) (string, error) { return o.o.Hash(ht) }
+
+// Storable returns whether this object can be stored
+func (o *Object) Storable() bool { return o.o.Storable() }
+
+// SetModTime copies-up to upper before mutating if the object is on base
+func (o *Object) SetModTime(t time.Time) error {
+	if err := o.copyUp(); err != nil {
+		return err
+	}
+	return o.o.SetModTime(t)
+}
+
+// Open pulls the read through from whichever layer currently holds it
+func (o *Object) Open(options ...fs.OpenOption) (io.ReadCloser, error) {
+	return o.o.Open(options...)
+}
+
+// Update copies-up to upper before mutating if the object is on base
+func (o *Object) Update(in io.Reader, src fs.ObjectInfo) error {
+	if err := o.copyUp(); err != nil {
+		return err
+	}
+	return o.o.Update(in, src)
+}
+
+// copyUp materialises a base-layer object in the upper layer before any
+// mutation, leaving o pointing at the new upper-layer copy
+func (o *Object) copyUp() error {
+	if !o.onBase {
+		return nil
+	}
+	in, err := o.o.Open()
+	if err != nil {
+		return fmt.Errorf("overlay: copy-up open failed: %w", err)
+	}
+	defer in.Close()
+
+	info := fs.NewStaticObjectInfo(o.Remote(), o.o.ModTime(), o.o.Size(), true, nil, o.f.base)
+	upperObj, err := o.f.upper.Put(in, info)
+	if err != nil {
+		return fmt.Errorf("overlay: copy-up failed: %w", err)
+	}
+	o.o = upperObj
+	o.onBase = false
+	return nil
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs     = (*Fs)(nil)
+	_ fs.Object = (*Object)(nil)
+)
@@ -0,0 +1,91 @@
+package overlay_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+	_ "github.com/rmdashrf/rclone_acd_hack/fs/all"
+	"github.com/stretchr/testify/require"
+)
+
+// newOverlay builds an overlay: remote over two local temp directories,
+// which are removed once the test finishes
+func newOverlay(t *testing.T) (f fs.Fs, base, upper fs.Fs) {
+	baseDir, err := ioutil.TempDir("", "overlay-base")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(baseDir) })
+	upperDir, err := ioutil.TempDir("", "overlay-upper")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(upperDir) })
+
+	base, err = fs.NewFs(baseDir)
+	require.NoError(t, err)
+	upper, err = fs.NewFs(upperDir)
+	require.NoError(t, err)
+
+	fs.ConfigFileSet("TestOverlay", "type", "overlay")
+	fs.ConfigFileSet("TestOverlay", "base", baseDir)
+	fs.ConfigFileSet("TestOverlay", "upper", upperDir)
+
+	f, err = fs.NewFs("TestOverlay:")
+	require.NoError(t, err)
+	return f, base, upper
+}
+
+func TestOverlayShadowing(t *testing.T) {
+	f, base, upper := newOverlay(t)
+	t1 := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+
+	require.NoError(t, base.Mkdir(""))
+	require.NoError(t, upper.Mkdir(""))
+
+	basePut(t, base, "file.txt", "from base", t1)
+	basePut(t, upper, "file.txt", "from upper", t1)
+
+	o, err := f.NewObject("file.txt")
+	require.NoError(t, err)
+	require.EqualValues(t, len("from upper"), o.Size())
+}
+
+func TestOverlayWhiteout(t *testing.T) {
+	f, base, _ := newOverlay(t)
+	t1 := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+
+	require.NoError(t, base.Mkdir(""))
+	basePut(t, base, "gone.txt", "still here in base", t1)
+
+	o, err := f.NewObject("gone.txt")
+	require.NoError(t, err)
+	require.NoError(t, o.Remove())
+
+	_, err = f.NewObject("gone.txt")
+	require.Equal(t, fs.ErrorObjectNotFound, err)
+}
+
+func TestOverlayRemoveUpperOnlyDoesNotResurrectBase(t *testing.T) {
+	f, base, upper := newOverlay(t)
+	t1 := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+
+	require.NoError(t, base.Mkdir(""))
+	require.NoError(t, upper.Mkdir(""))
+
+	basePut(t, base, "shadowed.txt", "from base", t1)
+	basePut(t, upper, "shadowed.txt", "from upper", t1)
+
+	o, err := f.NewObject("shadowed.txt")
+	require.NoError(t, err)
+	require.NoError(t, o.Remove())
+
+	_, err = f.NewObject("shadowed.txt")
+	require.Equal(t, fs.ErrorObjectNotFound, err)
+}
+
+func basePut(t *testing.T, f fs.Fs, remote, content string, modTime time.Time) {
+	info := fs.NewStaticObjectInfo(remote, modTime, int64(len(content)), true, nil, nil)
+	_, err := f.Put(strings.NewReader(content), info)
+	require.NoError(t, err)
+}
@@ -0,0 +1,174 @@
+package fs
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrorSubDirEscape is returned when a path passed to a subFs tries to
+// escape the chroot via ".." segments
+var ErrorSubDirEscape = fmt.Errorf("subdir: path attempts to escape the chroot")
+
+// subFs re-roots parent at subdir, analogous to afero's BasePathFs. All
+// paths passed in are transparently prefixed with subdir before being
+// handed to parent, and stripped again on the way out.
+type subFs struct {
+	parent   Fs
+	subdir   string
+	features *Features
+}
+
+// subObject strips the subdir prefix back off Object.Remote()
+type subObject struct {
+	Object
+	subdir string
+}
+
+// rootedObjectInfo wraps an ObjectInfo overriding Remote() with a rooted path
+type rootedObjectInfo struct {
+	ObjectInfo
+	remote string
+}
+
+// Remote returns the overridden, rooted path
+func (oi *rootedObjectInfo) Remote() string { return oi.remote }
+
+// NewSubFs returns parent re-rooted at subdir, so callers only ever see
+// paths relative to subdir
+func NewSubFs(parent Fs, subdir string) (Fs, error) {
+	subdir = strings.Trim(path.Clean(subdir), "/")
+	if subdir == "." {
+		subdir = ""
+	}
+	f := &subFs{
+		parent: parent,
+		subdir: subdir,
+	}
+	f.features = parent.Features().Fill(f)
+	return f, nil
+}
+
+// rooted prefixes remote with subdir, rejecting attempts to escape it
+func (f *subFs) rooted(remote string) (string, error) {
+	clean := path.Clean(remote)
+	if clean == "." {
+		clean = ""
+	}
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", ErrorSubDirEscape
+	}
+	return path.Join(f.subdir, clean), nil
+}
+
+// unrooted strips subdir back off remote
+func (f *subFs) unrooted(remote string) string {
+	rel := strings.TrimPrefix(remote, f.subdir)
+	return strings.TrimPrefix(rel, "/")
+}
+
+func (f *subFs) wrapObject(o Object) Object {
+	if o == nil {
+		return nil
+	}
+	return &subObject{Object: o, subdir: f.subdir}
+}
+
+// Name of the remote
+func (f *subFs) Name() string { return f.parent.Name() }
+
+// Root of the remote, including the subdir
+func (f *subFs) Root() string { return path.Join(f.parent.Root(), f.subdir) }
+
+// String converts this Fs to a string
+func (f *subFs) String() string { return fmt.Sprintf("%s/%s", f.parent.String(), f.subdir) }
+
+// Precision passed straight through
+func (f *subFs) Precision() time.Duration { return f.parent.Precision() }
+
+// Hashes passed straight through
+func (f *subFs) Hashes() HashSet { return f.parent.Hashes() }
+
+// Features returns the optional features of this Fs
+func (f *subFs) Features() *Features { return f.features }
+
+// List the objects and directories below subdir/dir
+func (f *subFs) List(dir string) (DirEntries, error) {
+	full, err := f.rooted(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := f.parent.List(full)
+	if err != nil {
+		return nil, err
+	}
+	unrooted := make(DirEntries, len(entries))
+	for i, e := range entries {
+		if o, ok := e.(Object); ok {
+			unrooted[i] = f.wrapObject(o)
+		} else if d, ok := e.(Directory); ok {
+			unrooted[i] = NewDir(f.unrooted(d.Remote()), d.ModTime())
+		} else {
+			unrooted[i] = e
+		}
+	}
+	return unrooted, nil
+}
+
+// NewObject finds the Object at subdir/remote
+func (f *subFs) NewObject(remote string) (Object, error) {
+	full, err := f.rooted(remote)
+	if err != nil {
+		return nil, err
+	}
+	o, err := f.parent.NewObject(full)
+	if err != nil {
+		return nil, err
+	}
+	return f.wrapObject(o), nil
+}
+
+// Put writes src below subdir
+func (f *subFs) Put(in io.Reader, src ObjectInfo) (Object, error) {
+	full, err := f.rooted(src.Remote())
+	if err != nil {
+		return nil, err
+	}
+	o, err := f.parent.Put(in, &rootedObjectInfo{ObjectInfo: src, remote: full})
+	if err != nil {
+		return nil, err
+	}
+	return f.wrapObject(o), nil
+}
+
+// Mkdir creates subdir/dir
+func (f *subFs) Mkdir(dir string) error {
+	full, err := f.rooted(dir)
+	if err != nil {
+		return err
+	}
+	return f.parent.Mkdir(full)
+}
+
+// Rmdir removes subdir/dir
+func (f *subFs) Rmdir(dir string) error {
+	full, err := f.rooted(dir)
+	if err != nil {
+		return err
+	}
+	return f.parent.Rmdir(full)
+}
+
+// Remote strips the subdir prefix back off so callers see an unrooted path
+func (o *subObject) Remote() string {
+	rel := strings.TrimPrefix(o.Object.Remote(), o.subdir)
+	return strings.TrimPrefix(rel, "/")
+}
+
+// Check the interfaces are satisfied
+var (
+	_ Fs     = (*subFs)(nil)
+	_ Object = (*subObject)(nil)
+)
@@ -0,0 +1,302 @@
+package fs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NodeKind distinguishes a file leaf from a directory node in a Node tree
+type NodeKind int
+
+// Node kinds
+const (
+	NodeFile NodeKind = iota
+	NodeDir
+)
+
+// Node is one entry in a merkle-rooted summary of a remote subtree,
+// either a file (leaf) or a directory (with sorted children)
+type Node struct {
+	Name     string
+	Kind     NodeKind
+	Hash     string // content hash for a file, or the folded hash of Children for a dir
+	Size     int64  // file size, meaningless for a directory
+	Children []*Node
+}
+
+// ChangeKind is the kind of change TreeDiff found between two Nodes
+type ChangeKind int
+
+// Change kinds
+const (
+	Added ChangeKind = iota
+	Removed
+	Modified
+)
+
+// Change is one difference found by TreeDiff between two trees, identified
+// by its path relative to the TreeHash root
+type Change struct {
+	Path    string
+	Kind    ChangeKind
+	OldHash string
+	NewHash string
+}
+
+// treeHashEntry is one cached TreeHash result, timestamped so it can
+// expire under TreeHashCacheTTL even if nothing ever calls
+// InvalidateTreeHash
+type treeHashEntry struct {
+	node *Node
+	time time.Time
+}
+
+// treeHashMu guards treeHashCache, which is read and written from
+// whatever goroutines call TreeHash - e.g. concurrent sync/check/dedupe
+// operations in a long-running server
+var treeHashMu sync.Mutex
+
+// treeHashCache memoizes TreeHash(f, dir) per Fs so a sibling walk (or a
+// repeated overlap check against the same subtree) reuses the hash
+// instead of re-listing
+var treeHashCache = map[string]map[string]treeHashEntry{}
+
+// TreeHashCacheTTL bounds how long a cached TreeHash result is reused
+// before TreeHash re-walks the subtree, so a caller that forgets to call
+// InvalidateTreeHash after a mutation still sees fresh data eventually
+var TreeHashCacheTTL = 30 * time.Second
+
+// InvalidateTreeHash drops the cached TreeHash result for dir on f, if
+// any - callers that mutate a subtree (sync, check, dedupe) should call
+// this afterwards so the next TreeHash reflects the change immediately
+// rather than waiting out TreeHashCacheTTL
+func InvalidateTreeHash(f Fs, dir string) {
+	treeHashMu.Lock()
+	defer treeHashMu.Unlock()
+	delete(treeHashCache[f.String()], dir)
+}
+
+// TreeHash walks dir on f and returns a Node summarising the subtree: a
+// leaf Node per file (named + sized + content-hashed via f.Hashes()) and
+// a directory Node per folder, whose Hash folds in every child's
+// name/type/hash in sorted order so the directory hash is stable
+// regardless of listing order.
+func TreeHash(f Fs, dir string) (*Node, error) {
+	cacheKey := f.String()
+
+	treeHashMu.Lock()
+	cached, ok := treeHashCache[cacheKey]
+	if !ok {
+		cached = map[string]treeHashEntry{}
+		treeHashCache[cacheKey] = cached
+	}
+	entry, ok := cached[dir]
+	treeHashMu.Unlock()
+	if ok && time.Since(entry.time) < TreeHashCacheTTL {
+		return entry.node, nil
+	}
+
+	entries, err := ListDirSorted(f, true, dir)
+	if err != nil {
+		return nil, fmt.Errorf("treehash: failed to list %q: %w", dir, err)
+	}
+
+	n := &Node{Name: baseName(dir), Kind: NodeDir}
+	ht := f.Hashes().GetOne()
+	for _, e := range entries {
+		switch v := e.(type) {
+		case Object:
+			child := &Node{Name: baseName(v.Remote()), Kind: NodeFile, Size: v.Size()}
+			if ht != HashNone {
+				if sum, err := v.Hash(ht); err == nil && sum != "" {
+					child.Hash = sum
+				}
+			}
+			if child.Hash == "" {
+				// fall back to size+mtime when there's no common hash type
+				child.Hash = fmt.Sprintf("sizemtime:%d:%d", v.Size(), v.ModTime().UnixNano())
+			}
+			n.Children = append(n.Children, child)
+		case Directory:
+			child, err := TreeHash(f, v.Remote())
+			if err != nil {
+				return nil, err
+			}
+			n.Children = append(n.Children, child)
+		}
+	}
+
+	sort.Slice(n.Children, func(i, j int) bool { return n.Children[i].Name < n.Children[j].Name })
+	n.Hash = dirHash(n.Children)
+
+	treeHashMu.Lock()
+	treeHashCache[cacheKey][dir] = treeHashEntry{node: n, time: time.Now()}
+	treeHashMu.Unlock()
+	return n, nil
+}
+
+// dirHash folds each child's "name\0typebyte\0childHash\0" record into a
+// single SHA-256, in the children's already-sorted order
+func dirHash(children []*Node) string {
+	h := sha256.New()
+	for _, c := range children {
+		typeByte := byte('f')
+		if c.Kind == NodeDir {
+			typeByte = 'd'
+		}
+		fmt.Fprintf(h, "%s\x00%c\x00%s\x00", c.Name, typeByte, c.Hash)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// effectiveHash returns n.Hash, computing and caching it first if n is a
+// directory Node whose Hash was never set - e.g. one hand-built by a
+// caller that only fills in Name/Kind/Children rather than going through
+// TreeHash. Without this, two differing hand-built trees that both leave
+// Hash at its zero value would compare equal and treeDiff would wrongly
+// prune the whole subtree instead of descending into it.
+func effectiveHash(n *Node) string {
+	if n == nil {
+		return ""
+	}
+	if n.Kind == NodeDir && n.Hash == "" {
+		for _, c := range n.Children {
+			if c.Kind == NodeDir {
+				c.Hash = effectiveHash(c)
+			}
+		}
+		n.Hash = dirHash(n.Children)
+	}
+	return n.Hash
+}
+
+func baseName(remote string) string {
+	for i := len(remote) - 1; i >= 0; i-- {
+		if remote[i] == '/' {
+			return remote[i+1:]
+		}
+	}
+	return remote
+}
+
+// nameRooter is satisfied by an Fs or any other remote descriptor
+// exposing just the Name/Root pair Overlapping needs
+type nameRooter interface {
+	Name() string
+	Root() string
+}
+
+// Overlapping reports whether a and b are on the same remote config
+// section with one root a path-prefix of (or equal to) the other, purely
+// from Name()/Root() - no listing required.
+func Overlapping(a, b nameRooter) bool {
+	if a.Name() != b.Name() {
+		return false
+	}
+	ra, rb := normalizeRoot(a.Root()), normalizeRoot(b.Root())
+	return isAncestorDir(ra, rb) || isAncestorDir(rb, ra)
+}
+
+// normalizeRoot strips leading/trailing slashes and folds "." and "/"
+// down to "", so "", "/" and "root/toot/" all compare the way a caller
+// would expect
+func normalizeRoot(root string) string {
+	return strings.Trim(path.Clean("/"+root), "/")
+}
+
+// isAncestorDir reports whether short is "" (the remote's own root, an
+// ancestor of everything) or a path-segment-aligned prefix of long
+func isAncestorDir(short, long string) bool {
+	if short == "" || short == long {
+		return true
+	}
+	return strings.HasPrefix(long, short+"/")
+}
+
+// OverlappingTreeHash confirms whether fa and fb actually overlap by
+// comparing their root TreeHash, catching the same data reachable
+// through two differently-rooted remotes that Overlapping's path-prefix
+// check can't reason about (e.g. two config sections mounting the same
+// bucket under different names). It reuses TreeHash's own cache, so
+// repeated checks against the same pair of roots in a long-running
+// server don't re-walk either subtree each time.
+func OverlappingTreeHash(fa, fb Fs) (bool, error) {
+	ha, err := TreeHash(fa, "")
+	if err != nil {
+		return false, fmt.Errorf("treehash: failed to hash %v: %w", fa, err)
+	}
+	hb, err := TreeHash(fb, "")
+	if err != nil {
+		return false, fmt.Errorf("treehash: failed to hash %v: %w", fb, err)
+	}
+	return ha.Hash == hb.Hash, nil
+}
+
+// TreeDiff walks a and b in lock-step on sorted child names, pruning
+// whole subtrees whose directory hashes already match, and emitting
+// Added/Removed/Modified records for everything else.
+func TreeDiff(a, b *Node) ([]Change, error) {
+	return treeDiff("", a, b), nil
+}
+
+func treeDiff(prefix string, a, b *Node) []Change {
+	if a != nil && b != nil && effectiveHash(a) == effectiveHash(b) {
+		return nil
+	}
+
+	byName := func(nodes []*Node) map[string]*Node {
+		m := make(map[string]*Node, len(nodes))
+		for _, n := range nodes {
+			m[n.Name] = n
+		}
+		return m
+	}
+
+	var aChildren, bChildren map[string]*Node
+	if a != nil {
+		aChildren = byName(a.Children)
+	}
+	if b != nil {
+		bChildren = byName(b.Children)
+	}
+
+	names := map[string]bool{}
+	for n := range aChildren {
+		names[n] = true
+	}
+	for n := range bChildren {
+		names[n] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+
+	var changes []Change
+	for _, name := range sorted {
+		an, aok := aChildren[name]
+		bn, bok := bChildren[name]
+		path := name
+		if prefix != "" {
+			path = prefix + "/" + name
+		}
+		switch {
+		case !aok:
+			changes = append(changes, Change{Path: path, Kind: Added, NewHash: bn.Hash})
+		case !bok:
+			changes = append(changes, Change{Path: path, Kind: Removed, OldHash: an.Hash})
+		case an.Kind == NodeDir && bn.Kind == NodeDir:
+			changes = append(changes, treeDiff(path, an, bn)...)
+		case an.Hash != bn.Hash:
+			changes = append(changes, Change{Path: path, Kind: Modified, OldHash: an.Hash, NewHash: bn.Hash})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,96 @@
+package fs_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+	_ "github.com/rmdashrf/rclone_acd_hack/fs/all"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreeDiff(t *testing.T) {
+	leaf := func(name string, hash string) *fs.Node {
+		return &fs.Node{Name: name, Kind: fs.NodeFile, Hash: hash}
+	}
+	dir := func(name string, children ...*fs.Node) *fs.Node {
+		return &fs.Node{Name: name, Kind: fs.NodeDir, Children: children}
+	}
+
+	a := dir("", leaf("one.txt", "h1"), dir("sub", leaf("two.txt", "h2")))
+	b := dir("", leaf("one.txt", "h1"), dir("sub", leaf("two.txt", "h2")))
+
+	changes, err := fs.TreeDiff(a, b)
+	require.NoError(t, err)
+	assert.Empty(t, changes, "identical trees should produce no changes")
+
+	b = dir("", leaf("one.txt", "h1-modified"), dir("sub", leaf("two.txt", "h2"), leaf("three.txt", "h3")))
+	changes, err = fs.TreeDiff(a, b)
+	require.NoError(t, err)
+
+	byPath := map[string]fs.Change{}
+	for _, c := range changes {
+		byPath[c.Path] = c
+	}
+	require.Contains(t, byPath, "one.txt")
+	assert.Equal(t, fs.Modified, byPath["one.txt"].Kind)
+	require.Contains(t, byPath, "sub/three.txt")
+	assert.Equal(t, fs.Added, byPath["sub/three.txt"].Kind)
+}
+
+func TestTreeDiffUnsetRootHashStillDetectsChanges(t *testing.T) {
+	leaf := func(name string, hash string) *fs.Node {
+		return &fs.Node{Name: name, Kind: fs.NodeFile, Hash: hash}
+	}
+	// neither root here has Hash populated, unlike a tree built by
+	// TreeHash - treeDiff must not treat that as "both empty, so equal"
+	a := &fs.Node{Kind: fs.NodeDir, Children: []*fs.Node{leaf("one.txt", "h1")}}
+	b := &fs.Node{Kind: fs.NodeDir, Children: []*fs.Node{leaf("one.txt", "h1-modified")}}
+
+	changes, err := fs.TreeDiff(a, b)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, fs.Modified, changes[0].Kind)
+	assert.Equal(t, "one.txt", changes[0].Path)
+}
+
+func TestOverlappingTreeHash(t *testing.T) {
+	aDir, err := ioutil.TempDir("", "treehash-overlap-a")
+	require.NoError(t, err)
+	bDir, err := ioutil.TempDir("", "treehash-overlap-b")
+	require.NoError(t, err)
+
+	fa, err := fs.NewFs(aDir)
+	require.NoError(t, err)
+	fb, err := fs.NewFs(bDir)
+	require.NoError(t, err)
+	require.NoError(t, fa.Mkdir(""))
+	require.NoError(t, fb.Mkdir(""))
+
+	same, err := fs.OverlappingTreeHash(fa, fb)
+	require.NoError(t, err)
+	assert.True(t, same, "two empty trees hash the same regardless of remote")
+
+	info := fs.NewStaticObjectInfo("file.txt", time.Now(), 5, true, nil, nil)
+	_, err = fb.Put(strings.NewReader("hello"), info)
+	require.NoError(t, err)
+	fs.InvalidateTreeHash(fb, "")
+
+	same, err = fs.OverlappingTreeHash(fa, fb)
+	require.NoError(t, err)
+	assert.False(t, same, "fb now has an extra file, so the root hashes must differ")
+}
+
+func TestTreeDiffRemoved(t *testing.T) {
+	a := &fs.Node{Kind: fs.NodeDir, Children: []*fs.Node{{Name: "gone.txt", Kind: fs.NodeFile, Hash: "h1"}}}
+	b := &fs.Node{Kind: fs.NodeDir}
+
+	changes, err := fs.TreeDiff(a, b)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, fs.Removed, changes[0].Kind)
+	assert.Equal(t, "gone.txt", changes[0].Path)
+}
@@ -0,0 +1,368 @@
+// Package smb provides an interface to SMB/CIFS shares (Windows and Samba)
+package smb
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hirochachacha/go-smb2"
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+)
+
+// Register with Fs
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name:        "smb",
+		Description: "SMB / CIFS",
+		NewFs:       NewFs,
+		Options: []fs.Option{{
+			Name: "host",
+			Help: "SMB server hostname or IP address.",
+		}, {
+			Name:    "port",
+			Help:    "SMB server port.",
+			Default: 445,
+		}, {
+			Name: "share",
+			Help: "Share name, e.g. \"Users\".",
+		}, {
+			Name: "domain",
+			Help: "Windows/Samba domain, e.g. \"WORKGROUP\". Ignored when user is given as domain\\user.",
+		}, {
+			Name: "user",
+			Help: "Username, can also be passed as domain\\user.",
+		}, {
+			Name:       "pass",
+			Help:       "Password.",
+			IsPassword: true,
+		}, {
+			Name:    "use_kerberos",
+			Help:    "Authenticate using Kerberos rather than NTLM.",
+			Default: false,
+		}},
+	})
+}
+
+// Options defines the configuration for this backend
+type Options struct {
+	Host        string `config:"host"`
+	Port        int    `config:"port"`
+	Share       string `config:"share"`
+	Domain      string `config:"domain"`
+	User        string `config:"user"`
+	Pass        string `config:"pass"`
+	UseKerberos bool   `config:"use_kerberos"`
+}
+
+// Fs represents a remote SMB share
+type Fs struct {
+	name     string
+	root     string
+	opt      Options
+	features *fs.Features
+
+	mu   sync.Mutex
+	conn net.Conn
+	sess *smb2.Session
+	fs   *smb2.Share
+}
+
+// Object describes an SMB file
+type Object struct {
+	fs      *Fs
+	remote  string
+	size    int64
+	modTime time.Time
+}
+
+// parseDomainUser splits "DOMAIN\user" into domain and user, falling back to
+// the domain configured on the remote when there is no backslash.
+func parseDomainUser(opt *Options) (domain, user string) {
+	if i := strings.IndexByte(opt.User, '\\'); i >= 0 {
+		return opt.User[:i], opt.User[i+1:]
+	}
+	return opt.Domain, opt.User
+}
+
+// NewFs constructs an Fs from the path, container:path
+func NewFs(name, root string) (fs.Fs, error) {
+	opt := new(Options)
+	err := fs.ConfigFileGetStruct(name, opt)
+	if err != nil {
+		return nil, err
+	}
+	if opt.Host == "" {
+		return nil, fmt.Errorf("smb: host must be set")
+	}
+	if opt.Share == "" {
+		return nil, fmt.Errorf("smb: share must be set")
+	}
+
+	f := &Fs{
+		name: name,
+		root: strings.Trim(root, "/"),
+		opt:  *opt,
+	}
+	f.features = (&fs.Features{
+		CanHaveEmptyDirectories: true,
+	}).Fill(f)
+
+	if err := f.connect(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// connect dials the server and mounts the configured share
+func (f *Fs) connect() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	addr := net.JoinHostPort(f.opt.Host, strconv.Itoa(f.opt.Port))
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("smb: failed to dial %q: %w", addr, err)
+	}
+
+	domain, user := parseDomainUser(&f.opt)
+	dialer := &smb2.Dialer{
+		Initiator: &smb2.NTLMInitiator{
+			User:     user,
+			Password: f.opt.Pass,
+			Domain:   domain,
+		},
+	}
+	if f.opt.UseKerberos {
+		dialer.Initiator = &smb2.Krb5Initiator{
+			Username: user,
+			Domain:   domain,
+		}
+	}
+
+	sess, err := dialer.Dial(conn)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smb: session setup failed: %w", err)
+	}
+
+	share, err := sess.Mount(f.opt.Share)
+	if err != nil {
+		sess.Logoff()
+		conn.Close()
+		return fmt.Errorf("smb: failed to mount share %q: %w", f.opt.Share, err)
+	}
+
+	f.conn, f.sess, f.fs = conn, sess, share
+	return nil
+}
+
+// Name of the remote
+func (f *Fs) Name() string { return f.name }
+
+// Root of the remote
+func (f *Fs) Root() string { return f.root }
+
+// String converts this Fs to a string
+func (f *Fs) String() string {
+	return fmt.Sprintf("smb://%s/%s/%s", f.opt.Host, f.opt.Share, f.root)
+}
+
+// Precision is the time precision a remote can keep, SMB2 FILETIME is 100ns
+func (f *Fs) Precision() time.Duration { return time.Nanosecond * 100 }
+
+// Hashes returns the supported hash sets, SMB has none natively
+func (f *Fs) Hashes() fs.HashSet { return fs.HashSet(fs.HashNone) }
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features { return f.features }
+
+func (f *Fs) fullPath(remote string) string {
+	return path.Join(f.root, remote)
+}
+
+// List the objects and directories in dir into entries
+func (f *Fs) List(dir string) (entries fs.DirEntries, err error) {
+	fis, err := f.fs.ReadDir(f.fullPath(dir))
+	if err != nil {
+		return nil, fmt.Errorf("smb: readdir failed: %w", err)
+	}
+	for _, fi := range fis {
+		remote := path.Join(dir, fi.Name())
+		if fi.IsDir() {
+			entries = append(entries, fs.NewDir(remote, fi.ModTime()))
+		} else {
+			entries = append(entries, &Object{
+				fs:      f,
+				remote:  remote,
+				size:    fi.Size(),
+				modTime: fi.ModTime(),
+			})
+		}
+	}
+	return entries, nil
+}
+
+// NewObject finds the Object at remote
+func (f *Fs) NewObject(remote string) (fs.Object, error) {
+	fi, err := f.fs.Stat(f.fullPath(remote))
+	if err != nil {
+		return nil, fs.ErrorObjectNotFound
+	}
+	if fi.IsDir() {
+		return nil, fs.ErrorIsDir
+	}
+	return &Object{fs: f, remote: remote, size: fi.Size(), modTime: fi.ModTime()}, nil
+}
+
+// Put the object into the share
+func (f *Fs) Put(in io.Reader, src fs.ObjectInfo) (fs.Object, error) {
+	o := &Object{fs: f, remote: src.Remote()}
+	return o, o.Update(in, src)
+}
+
+// Mkdir creates the directory if it doesn't exist
+func (f *Fs) Mkdir(dir string) error {
+	full := f.fullPath(dir)
+	if full == "" || full == "." {
+		return nil
+	}
+	err := f.fs.MkdirAll(full, 0777)
+	if err != nil {
+		return fmt.Errorf("smb: mkdir %q failed: %w", full, err)
+	}
+	return nil
+}
+
+// Rmdir removes the directory, which must be empty
+func (f *Fs) Rmdir(dir string) error {
+	err := f.fs.Remove(f.fullPath(dir))
+	if err != nil {
+		return fmt.Errorf("smb: rmdir %q failed: %w", dir, err)
+	}
+	return nil
+}
+
+// Move src to this remote using server-side rename
+func (f *Fs) Move(src fs.Object, remote string) (fs.Object, error) {
+	srcObj, ok := src.(*Object)
+	if !ok {
+		return nil, fs.ErrorCantMove
+	}
+	if srcObj.fs.opt.Host != f.opt.Host || srcObj.fs.opt.Share != f.opt.Share {
+		return nil, fs.ErrorCantMove
+	}
+	if err := f.Mkdir(path.Dir(remote)); err != nil {
+		return nil, err
+	}
+	if err := f.fs.Rename(srcObj.fs.fullPath(srcObj.remote), f.fullPath(remote)); err != nil {
+		return nil, fmt.Errorf("smb: rename failed: %w", err)
+	}
+	return f.NewObject(remote)
+}
+
+// DirMove moves src, srcRemote to this remote at dstRemote using server-side rename
+func (f *Fs) DirMove(src fs.Fs, srcRemote, dstRemote string) error {
+	srcFs, ok := src.(*Fs)
+	if !ok || srcFs.opt.Host != f.opt.Host || srcFs.opt.Share != f.opt.Share {
+		return fs.ErrorCantDirMove
+	}
+	if err := f.Mkdir(path.Dir(dstRemote)); err != nil {
+		return err
+	}
+	return f.fs.Rename(srcFs.fullPath(srcRemote), f.fullPath(dstRemote))
+}
+
+// ------------------------------------------------------------
+
+// Fs returns the parent Fs
+func (o *Object) Fs() fs.Info { return o.fs }
+
+// Remote returns the remote path
+func (o *Object) Remote() string { return o.remote }
+
+// String returns a description of the Object
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.remote
+}
+
+// Size returns the size of the file
+func (o *Object) Size() int64 { return o.size }
+
+// ModTime returns the modification time
+func (o *Object) ModTime() time.Time { return o.modTime }
+
+// Hash returns "" since SMB has no native hash
+func (o *Object) Hash(fs.HashType) (string, error) { return "", fs.ErrHashUnsupported }
+
+// Storable returns whether this object can be stored
+func (o *Object) Storable() bool { return true }
+
+// SetModTime sets modtime using an SMB2 SET_INFO request
+func (o *Object) SetModTime(t time.Time) error {
+	f, err := o.fs.fs.OpenFile(o.fs.fullPath(o.remote), 0, 0)
+	if err != nil {
+		return fmt.Errorf("smb: open for setmodtime failed: %w", err)
+	}
+	defer f.Close()
+	if err := f.Chtimes(t, t); err != nil {
+		return fmt.Errorf("smb: set-info failed: %w", err)
+	}
+	o.modTime = t
+	return nil
+}
+
+// Open an object for read
+func (o *Object) Open(options ...fs.OpenOption) (io.ReadCloser, error) {
+	f, err := o.fs.fs.Open(o.fs.fullPath(o.remote))
+	if err != nil {
+		return nil, fmt.Errorf("smb: open failed: %w", err)
+	}
+	return f, nil
+}
+
+// Update the object with new content
+func (o *Object) Update(in io.Reader, src fs.ObjectInfo) error {
+	full := o.fs.fullPath(o.remote)
+	if err := o.fs.Mkdir(path.Dir(o.remote)); err != nil {
+		return err
+	}
+	wc, err := o.fs.fs.Create(full)
+	if err != nil {
+		return fmt.Errorf("smb: create failed: %w", err)
+	}
+	_, err = io.Copy(wc, in)
+	if closeErr := wc.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("smb: write failed: %w", err)
+	}
+	o.size = src.Size()
+	o.modTime = src.ModTime()
+	return o.SetModTime(src.ModTime())
+}
+
+// Remove the object
+func (o *Object) Remove() error {
+	if err := o.fs.fs.Remove(o.fs.fullPath(o.remote)); err != nil {
+		return fmt.Errorf("smb: remove failed: %w", err)
+	}
+	return nil
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs       = (*Fs)(nil)
+	_ fs.Mover    = (*Fs)(nil)
+	_ fs.DirMover = (*Fs)(nil)
+	_ fs.Object   = (*Object)(nil)
+)
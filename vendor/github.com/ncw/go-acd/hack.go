@@ -1,16 +1,151 @@
 package acd
 
 import (
-	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 
 	"github.com/rmdashrf/go-misc/cookiejar2"
-	"github.com/rmdashrf/go-misc/editthiscookie"
+	"github.com/rmdashrf/go-misc/cookies"
 )
 
+// CookieSource loads a session for the ACD remote and installs it into jar.
+// Implementations are scoped to a single remote - unlike the old
+// ACD_COOKIEHACK env var, multiple ACD remotes can each carry their own
+// CookieSource and therefore their own session.
+type CookieSource interface {
+	// Load reads the underlying cookie store and returns the cookies
+	// that should be presented to amazon.com.
+	Load() ([]*http.Cookie, error)
+}
+
+// Session wraps a CookieSource with the resulting http.CookieJar and
+// SessionId, and knows how to refresh itself when the server starts
+// rejecting the current cookies (typically surfaced as a 401).
+type Session struct {
+	Source      CookieSource
+	Jar         http.CookieJar
+	Cookies     []*http.Cookie
+	SessionId   string
+	PersistPath string // if set, Jar survives restarts (see cookie_jar_persist_path on the backend)
+}
+
+// amazonURL is the domain the ACD cookie jar is scoped to
+var amazonURL, _ = url.Parse("https://www.amazon.com")
+
+// NewSession builds a Session from source, loading it immediately
+func NewSession(source CookieSource) (*Session, error) {
+	s := &Session{Source: source}
+	if err := s.Refresh(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewPersistentSession is NewSession, but the resulting Jar snapshots
+// itself to persistPath (AES-GCM encrypted) after every cookie refresh,
+// and is preloaded from it on startup, instead of the plain in-memory
+// jar NewSession gives. The legacy ACD_COOKIEHACK init path below (see
+// the deprecated globals further down) calls this instead of NewSession
+// when ACD_COOKIEHACK_PERSIST_PATH is also set - that env var pairing is
+// the only thing selecting it today, not a backend config key.
+func NewPersistentSession(source CookieSource, persistPath string) (*Session, error) {
+	s := &Session{Source: source, PersistPath: persistPath}
+	if err := s.Refresh(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Refresh reloads the cookies from the underlying CookieSource and
+// reinstalls them into the jar. Call this when a request comes back
+// with a 401 so a long running `rclone mount` can pick up new cookies
+// without needing a restart.
+func (s *Session) Refresh() error {
+	cookies, err := s.Source.Load()
+	if err != nil {
+		return fmt.Errorf("acd: failed to load cookies: %w", err)
+	}
+	for _, c := range cookies {
+		if c.Name == "session-id" {
+			s.SessionId = c.Value
+		}
+	}
+
+	jar, err := s.newJar()
+	if err != nil {
+		return fmt.Errorf("acd: failed to build cookie jar: %w", err)
+	}
+	jar.SetCookies(amazonURL, cookies)
+	s.Cookies = cookies
+	s.Jar = jar
+	return nil
+}
+
+// newJar builds the plain in-memory jar, or the persistent one when
+// PersistPath is set
+func (s *Session) newJar() (http.CookieJar, error) {
+	if s.PersistPath == "" {
+		return cookiejar2.New(nil), nil
+	}
+	return cookiejar2.NewPersistentJar(s.PersistPath, cookiejar2.New(nil))
+}
+
+// RefreshOn401 refreshes the session if resp is a 401, returning whether a
+// refresh was attempted so the caller can decide to retry the request.
+func (s *Session) RefreshOn401(resp *http.Response) bool {
+	if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	if err := s.Refresh(); err != nil {
+		log.Printf("acd: cookie refresh after 401 failed: %v\n", err)
+		return false
+	}
+	return true
+}
+
+// EditThisCookieSource loads cookies from an EditThisCookie JSON export
+type EditThisCookieSource struct {
+	Path string
+}
+
+// Load implements CookieSource
+func (e *EditThisCookieSource) Load() ([]*http.Cookie, error) {
+	return cookies.LoadCookies(e.Path)
+}
+
+// NetscapeCookieSource loads cookies from a Netscape/Mozilla cookies.txt
+// file, as produced by yt-dlp and most browser cookie-export extensions.
+type NetscapeCookieSource struct {
+	Path string
+}
+
+// Load implements CookieSource
+func (n *NetscapeCookieSource) Load() ([]*http.Cookie, error) {
+	return loadNetscapeCookies(n.Path)
+}
+
+// BrowserCookieSource reads the cookie store of an installed browser
+// (Chrome or Firefox) directly and extracts cookies scoped to Domain.
+type BrowserCookieSource struct {
+	Browser string // "chrome" or "firefox"
+	Domain  string
+}
+
+// Load implements CookieSource
+func (b *BrowserCookieSource) Load() ([]*http.Cookie, error) {
+	return loadBrowserCookies(b.Browser, b.Domain)
+}
+
+// Deprecated globals fed by the ACD_COOKIEHACK/ACD_COOKIEHACK_PERSIST_PATH
+// env vars below. CookieSource and Session (above) are what a per-remote,
+// config-key-driven selection would be built on, but no amazonclouddrive
+// backend package exists in this tree to carry a "cookie_source" config
+// key and construct one - there is nothing here that actually reads such
+// a key. Until that backend exists, this env-var path remains the only
+// wired-up way to get a session, not a fallback alongside a real one.
 var (
 	CookieHack bool
 	Jar        http.CookieJar
@@ -18,40 +153,32 @@ var (
 	SessionId  string
 )
 
+// init wires the legacy ACD_COOKIEHACK env var into the deprecated
+// globals above. This is a stopgap, not a design choice: see the comment
+// on those globals for what's missing to retire it.
 func init() {
 	cookieHackFile := os.Getenv("ACD_COOKIEHACK")
 	if cookieHackFile != "" {
-		loadCookies(cookieHackFile)
+		loadCookies(cookieHackFile, os.Getenv("ACD_COOKIEHACK_PERSIST_PATH"))
 	}
 }
 
-func loadCookies(file string) {
-	f, err := os.Open(file)
-	if err != nil {
-		log.Printf("Cookiehack: failed to open cookiefile: %v\n", err)
-		return
+func loadCookies(file, persistPath string) {
+	var sess *Session
+	var err error
+	if persistPath != "" {
+		sess, err = NewPersistentSession(&EditThisCookieSource{Path: file}, persistPath)
+	} else {
+		sess, err = NewSession(&EditThisCookieSource{Path: file})
 	}
-
-	defer f.Close()
-
-	var entries []editthiscookie.Entry
-	if err := json.NewDecoder(f).Decode(&entries); err != nil {
-		log.Printf("Could not load cookies: %v\n", err)
+	if err != nil {
+		log.Printf("Cookiehack: %v\n", err)
 		return
 	}
 
-	for _, e := range entries {
-		Cookies = append(Cookies, e.GoCookie())
-		if e.Name == "session-id" {
-			SessionId = e.Value
-		}
-	}
-
-	log.Printf("cookiehack: loaded %d cookies with sessionid %s\n", len(Cookies), SessionId)
+	log.Printf("cookiehack: loaded %d cookies with sessionid %s\n", len(sess.Cookies), sess.SessionId)
 	CookieHack = true
-
-	jar := cookiejar2.New(nil)
-	aznUrl, _ := url.Parse("https://www.amazon.com")
-	jar.SetCookies(aznUrl, Cookies)
-	Jar = jar
+	Jar = sess.Jar
+	Cookies = sess.Cookies
+	SessionId = sess.SessionId
 }
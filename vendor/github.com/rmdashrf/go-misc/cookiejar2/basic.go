@@ -0,0 +1,37 @@
+package cookiejar2
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// BasicJar is a minimal http.CookieJar that stores a fixed set of
+// cookies per host. Unlike net/http/cookiejar.Jar it does no
+// public-suffix matching or Set-Cookie parsing, which makes it a good
+// fit for cookies that were loaded whole from a file rather than
+// accumulated from server responses.
+type BasicJar struct {
+	mu      sync.Mutex
+	cookies map[string][]*http.Cookie
+}
+
+// New creates an empty BasicJar. The argument is accepted for parity
+// with net/http/cookiejar.New and is currently unused.
+func New(_ interface{}) *BasicJar {
+	return &BasicJar{cookies: make(map[string][]*http.Cookie)}
+}
+
+// SetCookies implements http.CookieJar
+func (j *BasicJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.cookies[u.Host] = cookies
+}
+
+// Cookies implements http.CookieJar
+func (j *BasicJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.cookies[u.Host]
+}
@@ -0,0 +1,152 @@
+package cookiejar2
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/rmdashrf/rclone_acd_hack/fs"
+)
+
+// PersistentCookieJar wraps an inner http.CookieJar and snapshots its
+// cookies to an AES-GCM encrypted file on disk after every SetCookies
+// call, so a long running process (or its next invocation) can resume
+// a session without re-importing cookies from scratch.
+type PersistentCookieJar struct {
+	Inner http.CookieJar
+	path  string
+	key   [32]byte
+	urls  map[string]*url.URL // registered URLs, keyed by host, so save() knows what to snapshot
+}
+
+// persistentEntry is one registered URL's cookies, as stored on disk
+type persistentEntry struct {
+	URL     string         `json:"url"`
+	Cookies []*http.Cookie `json:"cookies"`
+}
+
+// NewPersistentJar wraps inner with on-disk persistence at path. If a
+// snapshot already exists at path it is decrypted and loaded into inner
+// before NewPersistentJar returns. The encryption key is derived from
+// the RCLONE_CONFIG_PASS config password, obscured the same way any
+// other remote secret is, so it reuses fs.Reveal rather than ever
+// handling that password as plaintext itself.
+func NewPersistentJar(path string, inner http.CookieJar) (*PersistentCookieJar, error) {
+	key, err := derivePersistKey()
+	if err != nil {
+		return nil, err
+	}
+	j := &PersistentCookieJar{Inner: inner, path: path, key: key, urls: map[string]*url.URL{}}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// derivePersistKey turns RCLONE_CONFIG_PASS into an AES-256 key
+func derivePersistKey() ([32]byte, error) {
+	var key [32]byte
+	obscured := os.Getenv("RCLONE_CONFIG_PASS")
+	if obscured == "" {
+		return key, fmt.Errorf("cookiejar2: RCLONE_CONFIG_PASS must be set to use a PersistentCookieJar")
+	}
+	password, err := fs.Reveal(obscured)
+	if err != nil {
+		return key, fmt.Errorf("cookiejar2: failed to reveal RCLONE_CONFIG_PASS: %w", err)
+	}
+	return sha256.Sum256([]byte(password)), nil
+}
+
+// SetCookies implements http.CookieJar, remembering u so it's included
+// in future snapshots, then persisting immediately
+func (j *PersistentCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Inner.SetCookies(u, cookies)
+	j.urls[u.Host] = u
+	if err := j.save(); err != nil {
+		fmt.Fprintf(os.Stderr, "cookiejar2: failed to persist cookies to %s: %v\n", j.path, err)
+	}
+}
+
+// Cookies implements http.CookieJar
+func (j *PersistentCookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.Inner.Cookies(u)
+}
+
+func (j *PersistentCookieJar) save() error {
+	entries := make([]persistentEntry, 0, len(j.urls))
+	for _, u := range j.urls {
+		entries = append(entries, persistentEntry{URL: u.String(), Cookies: j.Inner.Cookies(u)})
+	}
+	plain, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := j.gcm()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	ciphertext := gcm.Seal(nonce, nonce, plain, nil)
+	return ioutil.WriteFile(j.path, ciphertext, 0600)
+}
+
+func (j *PersistentCookieJar) load() error {
+	raw, err := ioutil.ReadFile(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	gcm, err := j.gcm()
+	if err != nil {
+		return err
+	}
+	nonceSize := gcm.NonceSize()
+	if len(raw) < nonceSize {
+		return fmt.Errorf("cookiejar2: persisted cookie file %q is truncated", j.path)
+	}
+	nonce, ciphertext := raw[:nonceSize], raw[nonceSize:]
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return fmt.Errorf("cookiejar2: failed to decrypt %q, wrong RCLONE_CONFIG_PASS?: %w", j.path, err)
+	}
+
+	var entries []persistentEntry
+	if err := json.Unmarshal(plain, &entries); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			continue
+		}
+		j.Inner.SetCookies(u, e.Cookies)
+		j.urls[u.Host] = u
+	}
+	return nil
+}
+
+func (j *PersistentCookieJar) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(j.key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Check the interface is satisfied
+var _ http.CookieJar = (*PersistentCookieJar)(nil)
@@ -0,0 +1,103 @@
+// Package cookies is a pluggable cookie-import subsystem. It can load
+// cookies from an EditThisCookie JSON export, a Netscape/Mozilla
+// cookies.txt file, or a Firefox cookies.sqlite profile database, and
+// wrap the result in a read-only http.CookieJar.
+package cookies
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/rmdashrf/go-misc/cookiejar2"
+)
+
+// Reader loads cookies from a single file format
+type Reader interface {
+	Read(path string) ([]*http.Cookie, error)
+}
+
+// readers in extension-sniff order
+var readersByExt = map[string]Reader{
+	".json":   jsonReader{},
+	".txt":    netscapeReader{},
+	".sqlite": firefoxReader{},
+}
+
+// LoadCookies reads path using whichever Reader matches its format. The
+// format is sniffed first by extension, then by peeking at the file's
+// first bytes for the SQLite magic or a Netscape cookies.txt header.
+func LoadCookies(path string) ([]*http.Cookie, error) {
+	r, err := sniff(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.Read(path)
+}
+
+// NewJarFromFile loads path and wraps the resulting cookies in a
+// read-only jar, ready to hand to an http.Client
+func NewJarFromFile(path string) (http.CookieJar, error) {
+	loaded, err := LoadCookies(path)
+	if err != nil {
+		return nil, err
+	}
+
+	byDomain := map[string][]*http.Cookie{}
+	for _, c := range loaded {
+		domain := strings.TrimPrefix(c.Domain, ".")
+		byDomain[domain] = append(byDomain[domain], c)
+	}
+
+	inner := cookiejar2.New(nil)
+	for domain, cookies := range byDomain {
+		inner.SetCookies(&url.URL{Scheme: "https", Host: domain}, cookies)
+	}
+	return &cookiejar2.ImmutableCookieJar{Inner: inner}, nil
+}
+
+func sniff(path string) (Reader, error) {
+	if r, ok := readersByExt[strings.ToLower(extOf(path))]; ok {
+		return r, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cookies: failed to open %q: %w", path, err)
+	}
+	defer f.Close()
+
+	magic := make([]byte, 16)
+	n, _ := f.Read(magic)
+	magic = magic[:n]
+
+	switch {
+	case strings.HasPrefix(string(magic), "SQLite format 3"):
+		return firefoxReader{}, nil
+	case strings.HasPrefix(string(magic), "["), strings.HasPrefix(string(magic), "{"):
+		return jsonReader{}, nil
+	case strings.HasPrefix(string(magic), "# Netscape"), strings.HasPrefix(string(magic), "# HTTP Cookie"):
+		return netscapeReader{}, nil
+	}
+
+	// last resort: a bare cookies.txt has no recognisable header, only
+	// tab-separated data lines
+	scanner := bufio.NewScanner(f)
+	if scanner.Scan() {
+		if strings.Count(scanner.Text(), "\t") == 6 {
+			return netscapeReader{}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("cookies: could not determine the format of %q", path)
+}
+
+func extOf(path string) string {
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[i:]
+	}
+	return ""
+}
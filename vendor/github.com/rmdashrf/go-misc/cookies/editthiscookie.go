@@ -0,0 +1,32 @@
+package cookies
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/rmdashrf/go-misc/editthiscookie"
+)
+
+// jsonReader reads an EditThisCookie JSON export
+type jsonReader struct{}
+
+func (jsonReader) Read(path string) ([]*http.Cookie, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cookies: failed to open %q: %w", path, err)
+	}
+	defer f.Close()
+
+	var entries []editthiscookie.Entry
+	if err := json.NewDecoder(f).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("cookies: failed to decode EditThisCookie export: %w", err)
+	}
+
+	out := make([]*http.Cookie, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e.GoCookie())
+	}
+	return out, nil
+}
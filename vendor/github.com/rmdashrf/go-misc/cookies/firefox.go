@@ -0,0 +1,65 @@
+package cookies
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	// registers the "sqlite3" driver used below
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// firefoxReader reads a Firefox profile's cookies.sqlite database
+// directly. The file is opened read-only so this works even while
+// Firefox itself has it open.
+type firefoxReader struct{}
+
+func (firefoxReader) Read(path string) ([]*http.Cookie, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro&immutable=1")
+	if err != nil {
+		return nil, fmt.Errorf("cookies: failed to open %q: %w", path, err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT host, name, value, path, expiry, isSecure, isHttpOnly, sameSite FROM moz_cookies`)
+	if err != nil {
+		return nil, fmt.Errorf("cookies: failed to query %q: %w", path, err)
+	}
+	defer rows.Close()
+
+	var out []*http.Cookie
+	for rows.Next() {
+		var (
+			host, name, value, cpath    string
+			expiry                      int64
+			isSecure, isHTTPOnly, same8 int
+		)
+		if err := rows.Scan(&host, &name, &value, &cpath, &expiry, &isSecure, &isHTTPOnly, &same8); err != nil {
+			return nil, fmt.Errorf("cookies: failed to scan moz_cookies row: %w", err)
+		}
+		out = append(out, &http.Cookie{
+			Domain:   host,
+			Name:     name,
+			Value:    value,
+			Path:     cpath,
+			Expires:  unixOrZero(expiry),
+			Secure:   isSecure != 0,
+			HttpOnly: isHTTPOnly != 0,
+			SameSite: sameSiteOf(same8),
+		})
+	}
+	return out, rows.Err()
+}
+
+// sameSiteOf maps Firefox's moz_cookies.sameSite integer (0 = None,
+// 1 = Lax, 2 = Strict) to the Go http.SameSite constants.
+func sameSiteOf(v int) http.SameSite {
+	switch v {
+	case 1:
+		return http.SameSiteLaxMode
+	case 2:
+		return http.SameSiteStrictMode
+	default:
+		return http.SameSiteNoneMode
+	}
+}
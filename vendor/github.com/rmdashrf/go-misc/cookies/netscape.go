@@ -0,0 +1,67 @@
+package cookies
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// netscapeReader reads a Netscape/Mozilla cookies.txt file: tab-separated
+// fields "domain flag path secure expiration name value", with the
+// "#HttpOnly_" prefix some exporters use to mark HttpOnly cookies within
+// the otherwise-commented header lines.
+type netscapeReader struct{}
+
+func (netscapeReader) Read(path string) ([]*http.Cookie, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cookies: failed to open cookies.txt: %w", err)
+	}
+	defer f.Close()
+
+	var out []*http.Cookie
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			httpOnly = true
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+		} else if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			continue
+		}
+		expiry, _ := strconv.ParseInt(fields[4], 10, 64)
+		out = append(out, &http.Cookie{
+			Domain:   fields[0],
+			Path:     fields[2],
+			Secure:   fields[3] == "TRUE",
+			Expires:  unixOrZero(expiry),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cookies: failed to read cookies.txt: %w", err)
+	}
+	return out, nil
+}
+
+// unixOrZero converts a cookies.txt expiry (0 meaning session cookie)
+// into a time.Time, leaving session cookies with a zero Expires as
+// net/http expects.
+func unixOrZero(expiry int64) time.Time {
+	if expiry == 0 {
+		return time.Time{}
+	}
+	return time.Unix(expiry, 0)
+}
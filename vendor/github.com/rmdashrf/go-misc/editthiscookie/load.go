@@ -2,6 +2,8 @@ package editthiscookie
 
 import (
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -21,16 +23,80 @@ type Entry struct {
 	Id             int    `json:"id"`
 }
 
+// GoCookie converts e to the equivalent http.Cookie
 func (e *Entry) GoCookie() *http.Cookie {
-	expiration := time.Unix(int64(e.ExpirationDate), 0)
+	domain := e.Domain
+	if e.HostOnly {
+		// net/http/cookiejar only recognises a host-only cookie by an
+		// empty Domain field (it then implies the host from the request
+		// URL) - any non-empty Domain, dotted or not, is treated as a
+		// domain-match cookie that also covers subdomains. So clear it
+		// outright rather than just stripping a leading dot.
+		domain = ""
+	} else if !strings.HasPrefix(domain, ".") {
+		// HostOnly=false means the cookie applies to the domain and all
+		// of its subdomains, which net/http only honours via a leading dot
+		domain = "." + domain
+	}
 
-	return &http.Cookie{
+	c := &http.Cookie{
 		Name:     e.Name,
-		Value:    strings.Replace(e.Value, "\"", "", -1),
+		Value:    stripSurroundingQuotes(e.Value),
 		Path:     e.Path,
-		Domain:   e.Domain,
-		Expires:  expiration,
+		Domain:   domain,
 		Secure:   e.Secure,
 		HttpOnly: e.HttpOnly,
+		SameSite: sameSiteOf(e.SameSite),
+	}
+	if !e.Session {
+		c.Expires = time.Unix(int64(e.ExpirationDate), 0)
+	}
+	return c
+}
+
+// stripSurroundingQuotes removes a single pair of quotes wrapping value,
+// such as cookies whose raw content is a quoted JSON string, without
+// touching quotes that are part of the value itself
+func stripSurroundingQuotes(value string) string {
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// sameSiteOf maps the EditThisCookie export's sameSite string ("strict",
+// "lax", "no_restriction" or "unspecified") to the Go http.SameSite
+// constants
+func sameSiteOf(s string) http.SameSite {
+	switch s {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "lax":
+		return http.SameSiteLaxMode
+	case "no_restriction":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteDefaultMode
+	}
+}
+
+// ToJar builds a real net/http/cookiejar.Jar from entries, so an
+// EditThisCookie export can be handed straight to an http.Client without
+// going through the ImmutableCookieJar wrapper
+func ToJar(entries []Entry) (http.CookieJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	byHost := map[string][]*http.Cookie{}
+	for _, e := range entries {
+		host := strings.TrimPrefix(e.Domain, ".")
+		byHost[host] = append(byHost[host], e.GoCookie())
+	}
+	for host, cookies := range byHost {
+		u := &url.URL{Scheme: "https", Host: host}
+		jar.SetCookies(u, cookies)
 	}
+	return jar, nil
 }